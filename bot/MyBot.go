@@ -6,12 +6,13 @@ import (
 	"math/rand"
 	"os"
 	"runtime/pprof"
+	"strconv"
 	"strings"
 	"time"
 
 	ai "./ai"
 	hal "./core"
-	nav "./navigation"
+	ren "./render"
 )
 
 const (
@@ -23,23 +24,46 @@ func main() {
 
 	config := new(ai.Config)
 
-	flag.BoolVar(&config.Centre, "centre", false, "take the centre first (1v1)")
-	flag.BoolVar(&config.Conservative, "conservative", false, "no rushing")
-	flag.BoolVar(&config.DockOnly, "dockonly", false, "make initial dockings and stop")
-	flag.BoolVar(&config.ForceRush, "forcerush", false, "always rush")
-	flag.BoolVar(&config.Imperfect, "imperfect", false, "don't use \"perfect\" GA")
-	flag.BoolVar(&config.NoMsg, "nomsg", false, "no angle messages")
-	flag.BoolVar(&config.Profile, "profile", false, "run Golang CPU profile")
-	flag.BoolVar(&config.Split, "split", false, "split ships at start")
-	flag.BoolVar(&config.Timeseed, "timeseed", false, "seed RNG with time")
+	config.RegisterFlags(flag.CommandLine)
 
-	flag.Float64Var(&nav.Ignore_Collision_Dist, "icd", 100, "ignore collision distance (nav)")
+	log_level := flag.String("loglevel", "info", "log level: debug, info, warn or error")
+	log_text := flag.Bool("logtext", false, "also write the log as text")
 
-	flag.IntVar(&config.TestGA, "testga", -1, "test GA on thus turn")
+	trace_ships := flag.String("traceships", "", "trace decisions about these ships, e.g. 3,5-9 or all")
+	trace_turns := flag.String("traceturns", "", "only trace on these turns, e.g. 10-40,100- (default all)")
+	trace_file := flag.String("tracefile", "", "trace file (default trace<pid>.jsonl)")
+
+	record := flag.String("record", "", "record the session to this file")
+	playback := flag.String("playback", "", "play back a recorded session instead of talking to the engine")
+	live_view := flag.Bool("liveview", false, "draw the game in the terminal (on stderr) each turn")
 
 	flag.Parse()
 
-	game := hal.NewGame()
+	var transport hal.Transport = hal.StdioTransport()
+	var recorder *hal.TranscriptRecorder
+	var player *hal.TranscriptPlayer
+	var err error
+
+	if *playback != "" {
+		player, err = hal.NewTranscriptPlayer(*playback, os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		transport = player
+	}
+
+	if *record != "" {
+		recorder, err = hal.NewTranscriptRecorder(transport, *record)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		defer recorder.Close()
+		transport = recorder
+	}
+
+	game := hal.NewGameFromTransport(transport)
 
 	if config.Profile {
 		outfile, _ := os.Create("profile.prof")
@@ -53,36 +77,71 @@ func main() {
 	defer func() {
 		if p := recover(); p != nil {
 			fmt.Printf("%v", p)
-			game.Log("Quitting: %v", p)
-			game.Log("Last known hash: %s", hal.HashFromString(game.RawWorld()))
-			game.Log("Current ships...... %3d, %3d, %3d, %3d",
+			logger := game.Logger("main")
+			logger.Error("Quitting: %v", p)
+			logger.Error("Last known hash: %s", hal.HashFromString(game.RawWorld()))
+			logger.Error("Current ships...... %3d, %3d, %3d, %3d",
 				len(game.ShipsOwnedBy(0)),
 				len(game.ShipsOwnedBy(1)),
 				len(game.ShipsOwnedBy(2)),
 				len(game.ShipsOwnedBy(3)))
-			game.Log("Cumulative ships... %3d, %3d, %3d, %3d",
+			logger.Error("Cumulative ships... %3d, %3d, %3d, %3d",
 				game.GetCumulativeShipCount(0),
 				game.GetCumulativeShipCount(1),
 				game.GetCumulativeShipCount(2),
 				game.GetCumulativeShipCount(3))
-			game.Log("Longest turn (%d) took %v", longest_turn_number, longest_turn)
+			logger.Error("Longest turn (%d) took %v", longest_turn_number, longest_turn)
 		}
 	}()
 
-	game.StartLog(fmt.Sprintf("log%d.txt", game.Pid()))
-	game.LogWithoutTurn("--------------------------------------------------------------------------------")
-	game.LogWithoutTurn("%s %s starting up at %s", NAME, VERSION, time.Now().Format("2006-01-02T15:04:05Z"))
+	level, err := hal.ParseLogLevel(*log_level)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	text_filename := ""
+	if *log_text {
+		text_filename = fmt.Sprintf("log%d.txt", game.Pid())
+	}
+
+	game.StartLog(fmt.Sprintf("log%d.jsonl", game.Pid()), text_filename, level)
+	if *trace_ships != "" {
+
+		spec, err := hal.ParseTraceSpec(*trace_ships, *trace_turns)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		if *trace_file == "" {
+			*trace_file = fmt.Sprintf("trace%d.jsonl", game.Pid())
+		}
+
+		game.StartTrace(*trace_file, spec)
+	}
+
+	game.Logger("main").With("name", NAME).With("version", VERSION).With("args", os.Args[1:]).
+		Info("Starting up at %s", time.Now().Format("2006-01-02T15:04:05Z"))
 
 	if config.Timeseed {
 		seed := time.Now().UTC().UnixNano()
+		if player != nil {
+			if s, ok := player.Note("seed"); ok {
+				seed, _ = strconv.ParseInt(s, 10, 64)
+			}
+		}
+		if recorder != nil {
+			recorder.Note("seed", strconv.FormatInt(seed, 10))
+		}
 		rand.Seed(seed)
-		game.LogWithoutTurn("Seeding own RNG: %v", seed)
+		game.Logger("main").With("seed", seed).Info("Seeding own RNG")
 	}
 
 	if len(os.Args) < 2 {
-		fmt.Printf("%s %s\n", NAME, VERSION)
+		game.SendName(fmt.Sprintf("%s %s", NAME, VERSION))
 	} else {
-		fmt.Printf("%s %s %s\n", NAME, VERSION, strings.Join(os.Args[1:], " "))
+		game.SendName(fmt.Sprintf("%s %s %s", NAME, VERSION, strings.Join(os.Args[1:], " ")))
 	}
 
 	overmind := ai.NewOvermind(game, config)
@@ -90,6 +149,11 @@ func main() {
 	for {
 		start_time := time.Now()
 
+		if player != nil && player.Finished() {
+			fmt.Println(player.Summary())
+			return
+		}
+
 		game.Parse()
 
 		if config.Timeseed == false {
@@ -107,6 +171,16 @@ func main() {
 		overmind.Step()
 		game.Send(config.NoMsg)
 
+		if *live_view {
+			view := ren.NewTermView(game)
+			view.Stats[game.Pid()].TurnTime = time.Now().Sub(start_time)
+			view.Draw(os.Stderr)
+		}
+
+		if game.Budget().Overrun() > game.Budget().Reserve() {
+			game.Logger("main").With("overrun", game.Budget().Overrun()).With("reserve", game.Budget().Reserve()).Warn("Overran budget")
+		}
+
 		if time.Now().Sub(start_time) > longest_turn {
 			longest_turn = time.Now().Sub(start_time)
 			longest_turn_number = game.Turn()
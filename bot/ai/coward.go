@@ -5,6 +5,12 @@ import (
 	pil "../pilot"
 )
 
+const (
+	COWARD_RATIO = 10				// Flee when the enemy has this many times as many ships as us
+	COWARD_RATIO_AGGRESSIVE = 7		// ...or this many, when some enemy is a rusher or harasser
+	COWARD_FORECAST_TURNS = 10		// Counting ships that will spawn within this many turns
+)
+
 func (self *Overmind) CowardStep() {
 
 	var mobile_pilots []*pil.Pilot
@@ -22,7 +28,7 @@ func (self *Overmind) CowardStep() {
 		pilot.PlanCowardice(all_enemies, avoid_list)
 	}
 
-	pil.ExecuteSafely(mobile_pilots)
+	pil.ResolveMoves(mobile_pilots, avoid_list)
 
 	// Also undock any docked ships...
 
@@ -51,7 +57,29 @@ func (self *Overmind) SetCowardFlag() {
 		return
 	}
 
-	if self.Game.CountMyShips() < self.Game.CountEnemyShips() / 10 {
+	// Aggressive enemies will hunt down a weak player sooner, so flee sooner if any are left...
+
+	ratio := COWARD_RATIO
+
+	for _, pid := range self.Game.SurvivingPlayerIDs() {
+		if pid != self.Game.Pid() && (self.Opponent(pid).Is(RUSHER) || self.Opponent(pid).Is(HARASSER)) {
+			ratio = COWARD_RATIO_AGGRESSIVE
+		}
+	}
+
+	// Compare fleets as they'll be shortly, so we see enemy reinforcements coming rather than after they arrive...
+
+	my_count, enemy_count := 0, 0
+
+	for pid, counts := range self.Game.ForecastShipCounts(COWARD_FORECAST_TURNS) {
+		if pid == self.Game.Pid() {
+			my_count = counts[COWARD_FORECAST_TURNS]
+		} else {
+			enemy_count += counts[COWARD_FORECAST_TURNS]
+		}
+	}
+
+	if my_count < enemy_count / ratio {
 		self.CowardFlag = true
 	}
 }
@@ -14,25 +14,25 @@ func (self *Overmind) DecideRush() {
 
 	if self.Game.InitialPlayers() > 2 {
 		self.RushChoice = NOT_RUSHING
-		self.Game.Log("Not rushing because: self.Game.InitialPlayers() > 2")
+		self.Logger().With("reason", "self.Game.InitialPlayers() > 2").Info("Not rushing")
 		return
 	}
 
 	if len(self.Game.EnemyShips()) < 3 {	// If enemy ships crash, just beat the enemy normally.
 		self.RushChoice = NOT_RUSHING
-		self.Game.Log("Not rushing because: len(self.Game.EnemyShips()) < 3")
+		self.Logger().With("reason", "len(self.Game.EnemyShips()) < 3").Info("Not rushing")
 		return
 	}
 
 	if len(self.Game.MyShips()) < 3 {
 		self.RushChoice = NOT_RUSHING
-		self.Game.Log("Not rushing because: len(self.Game.MyShips()) < 3")
+		self.Logger().With("reason", "len(self.Game.MyShips()) < 3").Info("Not rushing")
 		return
 	}
 
 	if self.Game.WeHaveDockedShips() {
 		self.RushChoice = NOT_RUSHING
-		self.Game.Log("Not rushing because: self.Game.WeHaveDockedShips()")
+		self.Logger().With("reason", "self.Game.WeHaveDockedShips()").Info("Not rushing")
 		return
 	}
 
@@ -45,14 +45,22 @@ func (self *Overmind) DecideRush() {
 
 	if my_ships[0].Dist(centre_of_gravity) < 45 && my_ships[1].Dist(centre_of_gravity) < 48 && my_ships[2].Dist(centre_of_gravity) < 51 {
 		self.RushChoice = RUSHING
-		self.Game.Log("RUSHING!")
+		self.Logger().With("reason", "near centre").Info("RUSHING!")
+		return
+	}
+
+	// Whatever the distances, if the enemy isn't docking and is coming for us, docking now is suicide...
+
+	if self.Opponent(self.RushEnemyID).Is(RUSHER) {
+		self.RushChoice = RUSHING
+		self.Logger().With("reason", "enemy is a rusher").With("opponent", self.Opponent(self.RushEnemyID)).Info("RUSHING!")
 		return
 	}
 }
 
 func (self *Overmind) MaybeEndRush() {
 	if len(self.Game.ShipsOwnedBy(self.RushEnemyID)) == 0 {
-		self.Game.Log("Ending rush!")
+		self.Logger().Info("Ending rush!")
 		self.RushChoice = NOT_RUSHING
 	}
 }
@@ -268,12 +276,12 @@ func (self *Overmind) ConsiderCentreDocks() {
 	}
 
 	if yes == false && self.Config.Centre {
-		self.Game.Log("Sending all ships to centre because of --centre flag.")
+		self.Logger().Info("Sending all ships to centre because of --centre flag.")
 		yes = true
 	}
 
 	if yes == false && ships_to_centre > 0 && ships_to_centre < 3 {
-		self.Game.Log("Was going to send %d ships to centre; sending all instead.", ships_to_centre)
+		self.Logger().With("ships_to_centre", ships_to_centre).Info("Was going to send some ships to centre; sending all instead.")
 		yes = true
 	}
 
@@ -293,7 +301,7 @@ func (self *Overmind) ConsiderCentreDocks() {
 		cd := hal.MinFloat(self.Pilots[1].Dist(a), self.Pilots[1].Dist(b))
 
 		if cd - d < 21 {
-			self.Game.Log("Centre planets are close enough (diff == %v), going there.", cd - d)
+			self.Logger().With("diff", cd - d).Info("Centre planets are close enough, going there.")
 			yes = true
 		}
 	}
@@ -369,7 +377,7 @@ func (self *Overmind) CanAvoidBad2v1() bool {
 
 func (self *Overmind) AvoidBad2v1() {
 
-	self.Game.Log("Avoiding Bad 2v1: setting Overmind.RushChoice, Overmind.NeverGA; and choosing targets.")
+	self.Logger().Info("Avoiding Bad 2v1: setting Overmind.RushChoice, Overmind.NeverGA; and choosing targets.")
 
 	self.RushChoice = RUSHING									// Ensures the chaser continues to chase.
 	self.NeverGA = true
@@ -445,6 +453,8 @@ func (self *Overmind) EnterGeneticAlgorithm() {
 	// NOTE! Can be called by MyBot.go for debugging purposes, in which case self.Pilots won't be up to date.
 	// If we need to use self.Pilots here, do something about that.
 
+	self.LastGATurn = self.Game.Turn()
+
 	play_perfect := (self.Config.Imperfect == false)
 
 	if play_perfect {		// Sometimes we need to turn it off anyway
@@ -481,7 +491,7 @@ func (self *Overmind) EnterGeneticAlgorithm() {
 
 		if self.Game.RunOfSames() > 10 && rand.Intn(5) == 0 {
 			if play_perfect {
-				self.Game.Log("Taking a stab in the dark")
+				self.Logger().Info("Taking a stab in the dark")
 			}
 			play_perfect = false
 		}
@@ -1,11 +1,12 @@
 package ai
 
 import (
-	"math/rand"
+	"flag"
 	"sort"
 	// "time"
 
 	hal "../core"
+	nav "../navigation"
 	pil "../pilot"
 )
 
@@ -15,11 +16,6 @@ const (
 	RUSHING = 1
 )
 
-const (
-	DEBUG_TURN = -1
-	DEBUG_SHIP_ID = -1
-)
-
 // --------------------------------------------
 
 type Config struct {
@@ -34,6 +30,26 @@ type Config struct {
 	Timeseed				bool
 
 	TestGA					int
+
+	Nav						nav.Settings		// Handed to every pilot
+}
+
+func (self *Config) RegisterFlags(fs *flag.FlagSet) {
+
+	fs.BoolVar(&self.Centre, "centre", false, "take the centre first (1v1)")
+	fs.BoolVar(&self.Conservative, "conservative", false, "no rushing")
+	fs.BoolVar(&self.DockOnly, "dockonly", false, "make initial dockings and stop")
+	fs.BoolVar(&self.ForceRush, "forcerush", false, "always rush")
+	fs.BoolVar(&self.Imperfect, "imperfect", false, "don't use \"perfect\" GA")
+	fs.BoolVar(&self.NoMsg, "nomsg", false, "no angle messages")
+	fs.BoolVar(&self.Profile, "profile", false, "run Golang CPU profile")
+	fs.BoolVar(&self.Split, "split", false, "split ships at start")
+	fs.BoolVar(&self.Timeseed, "timeseed", false, "seed RNG with time")
+
+	fs.IntVar(&self.TestGA, "testga", -1, "test GA on thus turn")
+
+	fs.Float64Var(&self.Nav.IgnoreCollisionDist, "icd", 100, "ignore collisions further than this when pathing (nav); 0 = never ignore")
+	fs.BoolVar(&self.Nav.AvoidMovers, "movers", false, "avoid ships predicted to move (nav)")
 }
 
 type Overmind struct {
@@ -47,9 +63,12 @@ type Overmind struct {
 	NeverGA					bool
 	FirstLaunchTurn			int					// The turn we first had a chance to undock. -1 means never.
 	AvoidingBad2v1			bool				// AvoidBad2v1() has been called.
+	LastGATurn				int					// The last turn we entered the GA. -1 means never.
 
 	RushEnemiesTouched		map[int]bool		// For deciding whether we can enter GA.
 	EverDocked				bool				// Also allows us to enter the GA.
+
+	Opponents				map[int]*OpponentModel	// Player ID --> model. See Opponent().
 }
 
 func NewOvermind(game *hal.Game, config *Config) *Overmind {
@@ -69,6 +88,7 @@ func NewOvermind(game *hal.Game, config *Config) *Overmind {
 	}
 
 	ret.FirstLaunchTurn = -1
+	ret.LastGATurn = -1
 	ret.RushEnemiesTouched = make(map[int]bool)
 
 	return ret
@@ -78,6 +98,8 @@ func NewOvermind(game *hal.Game, config *Config) *Overmind {
 
 func (self *Overmind) Step() {
 
+	self.UpdateOpponents()
+
 	if self.EverDocked == false {
 		for _, ship := range self.Game.MyShips() {
 			if ship.DockedStatus != hal.UNDOCKED {
@@ -106,9 +128,18 @@ func (self *Overmind) Step() {
 
 	self.ResetPilots()
 
-	if self.FirstLaunchTurn == self.Game.Turn() && self.AvoidingBad2v1 == false {	// We have a docked ship for the first time. Emergency undock?
+	// We have a docked ship for the first time, or the enemy has just shown itself to be a rusher. Emergency undock?
+
+	rusher_spotted := false
+
+	if self.EverDocked && self.Game.Turn() < RUSHER_WATCH_TURNS {
+		enemy := self.Opponent(self.RushEnemyID)
+		rusher_spotted = enemy.Is(RUSHER) && enemy.StyleTurn == self.Game.Turn()
+	}
+
+	if (self.FirstLaunchTurn == self.Game.Turn() || rusher_spotted) && self.AvoidingBad2v1 == false {
 		if self.Config.Conservative == false {
-			self.Game.Log("Running late rush detector...")
+			self.Logger().Info("Running late rush detector...")
 			if self.LateRushDetector() {
 				self.RushChoice = RUSHING
 				self.ClearAllTargets()
@@ -118,6 +149,11 @@ func (self *Overmind) Step() {
 
 	self.SetCowardFlag()
 
+	for _, pilot := range self.Pilots {
+		pilot.Trace("overmind").With("rush", self.RushChoice).With("coward", self.CowardFlag).
+			With("avoiding_bad_2v1", self.AvoidingBad2v1).With("target", pilot.Target).With("locked", pilot.Locked)
+	}
+
 	if self.Game.Turn() == 0 {
 		if self.RushChoice != RUSHING {
 			self.ChooseThreeDocks()
@@ -136,17 +172,15 @@ func (self *Overmind) Step() {
 		if self.CanAvoidBad2v1() {
 			self.AvoidBad2v1()
 		} else {
+			for _, pilot := range self.Pilots {
+				pilot.Trace("genetic")						// The GA's own choices aren't traced, but its orders are.
+			}
 			self.EnterGeneticAlgorithm()
 			return
 		}
 	}
 
 	self.NormalStep()
-
-	self.DebugNavStack()
-	self.DebugInhibition()
-	self.DebugOrders()
-	self.DebugTargets()
 }
 
 func (self *Overmind) NormalStep() {
@@ -172,7 +206,7 @@ func (self *Overmind) ResetPilots() {
 	my_new_ships := self.Game.MyNewShipIDs()
 
 	for _, sid := range my_new_ships {
-		pilot := pil.NewPilot(sid, self.Game)
+		pilot := pil.NewPilot(sid, self.Game, &self.Config.Nav)
 		self.Pilots = append(self.Pilots, pilot)
 	}
 
@@ -209,10 +243,14 @@ func (self *Overmind) ExecuteMoves() {
 
 	ignore_inhibition := (self.RushChoice == RUSHING)
 
+	// Enemy ships that will probably die at time 0 can be ignored. Ours can't, since ValidateOrders() won't
+	// let us hit them; they might survive...
+
 	for _, entity := range raw_avoid_list {
 		switch entity.Type() {
 		case hal.SHIP:
-			if entity.(*hal.Ship).Doomed == false {
+			ship := entity.(*hal.Ship)
+			if ship.Owner == self.Game.Pid() || ship.DoomChance < hal.RISK_NAV_IGNORE {
 				avoid_list = append(avoid_list, entity)
 			}
 		default:
@@ -225,9 +263,17 @@ func (self *Overmind) ExecuteMoves() {
 	var mobile_pilots []*pil.Pilot
 	var frozen_pilots []*pil.Pilot				// Note that this doesn't include docked / docking / undocking ships.
 
+	// ...so our probably doomed ships just hold still, and everyone else goes round them.
+
 	for _, pilot := range self.Pilots {
-		if pilot.DockedStatus == hal.UNDOCKED && pilot.Doomed == false {
-			mobile_pilots = append(mobile_pilots, pilot)
+		if pilot.DockedStatus == hal.UNDOCKED {
+			if pilot.DoomChance < hal.RISK_NAV_IGNORE {
+				mobile_pilots = append(mobile_pilots, pilot)
+			} else {
+				pilot.PlanHold()
+				frozen_pilots = append(frozen_pilots, pilot)
+				avoid_list = append(avoid_list, pilot.Ship)
+			}
 		}
 	}
 
@@ -262,48 +308,9 @@ func (self *Overmind) ExecuteMoves() {
 
 	// Since our plans are based on the avoid_list, the only danger is 2 "mobile" ships colliding.
 	// Note that it's possible that one of the colliding ships will not actually be moving.
+	// Choose everyone's actual moves together so that doesn't happen...
 
-	pil.ExecuteSafely(mobile_pilots)
-
-	// Randomly give up for half the ships that still aren't moving, and
-	// retry the pathfinding with the other half.
-
-	for i := 0; i < len(mobile_pilots); i++ {
-		pilot := mobile_pilots[i]
-		if pilot.HasExecuted == false && rand.Intn(2) == 0 {
-			pilot.PlanThrust(0, 0)
-			pilot.Message = pil.MSG_ATC_DEACTIVATED
-			mobile_pilots = append(mobile_pilots[:i], mobile_pilots[i+1:]...)
-			frozen_pilots = append(frozen_pilots, pilot)
-			avoid_list = append(avoid_list, pilot.Ship)
-			i--
-		}
-	}
-
-	// Remake plans for our non-moving ships that we didn't freeze...
-
-	for _, pilot := range mobile_pilots {
-		if pilot.HasExecuted == false {
-			pilot.PlanChase(avoid_list, ignore_inhibition)
-		}
-	}
-
-	// And execute. Note that pilots that have already executed won't be affected...
-
-	pil.ExecuteSafely(mobile_pilots)
-
-	// Null thrust every "mobile" ship that didn't move. This causes target info to be put into
-	// the replay via the Angle Message system.
-
-	for _, pilot := range mobile_pilots {
-		if pilot.HasExecuted == false {
-			if pilot.Plan != "" {
-				pilot.PlanThrust(0, 0)
-				// pilot.Message = pil.MSG_ATC_RESTRICT
-				pilot.ExecutePlan()
-			}
-		}
-	}
+	pil.ResolveMoves(mobile_pilots, avoid_list)
 
 	// Don't forget our non-mobile ships!
 
@@ -316,62 +323,28 @@ func (self *Overmind) ExecuteMoves() {
 
 func (self *Overmind) DetectDanger() {
 
-	all_ships := self.Game.AllShips()
-
 	for _, pilot := range self.Pilots {
-		pilot.DetectDanger(all_ships)
+		pilot.DetectDanger()
 	}
 }
 
 // --------------------------------------------
 
-func (self *Overmind) DebugNavStack() {
-	if self.Game.Turn() == DEBUG_TURN {
-		for _, pilot := range self.Pilots {
-			if pilot.Id == DEBUG_SHIP_ID {
-				pilot.LogNavStack()
-				break
-			}
-		}
-	}
-}
-
-func (self *Overmind) DebugInhibition() {
-	if self.Game.Turn() == DEBUG_TURN {
-		for _, pilot := range self.Pilots {
-			if pilot.Id == DEBUG_SHIP_ID {
-				pilot.Log("Inhibition: %f; DangerShips: %d", pilot.Inhibition, len(pilot.DangerShips))
-				break
-			}
-		}
-	}
-}
-
-func (self *Overmind) DebugOrders() {
-	if self.Game.Turn() == DEBUG_TURN {
-		for _, pilot := range self.Pilots {
-			if pilot.Id == DEBUG_SHIP_ID {
-				pilot.Log("Docked: %v. Order: %v", pilot.DockedStatus, self.Game.CurrentOrder(pilot.Ship))
-			}
-		}
-	}
-}
-
-func (self *Overmind) DebugTargets() {
-	if self.Game.Turn() == DEBUG_TURN {
-		for _, pilot := range self.Pilots {
-			if pilot.Id == DEBUG_SHIP_ID {
-				pilot.Log("Target: %v", pilot.Target)
-			}
-		}
-	}
+func (self *Overmind) Logger() *hal.Logger {
+	return self.Game.Logger("ai")
 }
 
 // --------------------------------------------
 
 func (self *Overmind) LateRushDetector() bool {
 
-	// Called on the first turn when we can undock.
+	// Called on the first turn when we can undock, and when the enemy is first classified as a rusher after we docked.
+	// In 1v1, a rusher is enough: it isn't docking, so it's coming for our docked ships sooner or later.
+
+	if self.Game.InitialPlayers() == 2 && self.Opponent(self.RushEnemyID).Is(RUSHER) {
+		self.Logger().With("opponent", self.Opponent(self.RushEnemyID)).Info("LateRushDetector(): enemy is a rusher")
+		return true
+	}
 
 	relevant_enemies := self.Game.ShipsOwnedBy(self.RushEnemyID)
 	my_centre_of_gravity := self.Game.MyShipsCentreOfGravity()
@@ -380,7 +353,7 @@ func (self *Overmind) LateRushDetector() bool {
 
 	for _, enemy := range relevant_enemies {
 		if enemy.DockedStatus == hal.UNDOCKED {
-			if enemy.VagueDirection() == self.MyRushSide {
+			if enemy.VagueDirection() == self.MyRushSide || self.HeadingForUs(enemy) {
 				if enemy.Dist(my_centre_of_gravity) < 90 {
 					dangerous++
 				}
@@ -389,13 +362,32 @@ func (self *Overmind) LateRushDetector() bool {
 	}
 
 	if dangerous > 1 {
-		self.Game.Log("LateRushDetector(): dangerous == %v", dangerous)
+		self.Logger().With("dangerous", dangerous).Info("LateRushDetector(): rush detected")
 		return true
 	}
 
 	return false
 }
 
+func (self *Overmind) HeadingForUs(enemy *hal.Ship) bool {
+
+	// Whether the enemy ship seems to be going for one of our docked ships, or a planet we own.
+
+	intent := self.Game.Intent(enemy)
+
+	switch intent.Kind {
+
+	case hal.INTENT_DOCKED_SHIP:
+		return intent.Target.(*hal.Ship).Owner == self.Game.Pid()
+
+	case hal.INTENT_PLANET:
+		planet := intent.Target.(*hal.Planet)
+		return planet.Owned && planet.Owner == self.Game.Pid()
+	}
+
+	return false
+}
+
 func (self *Overmind) UndockAll() {
 	for _, pilot := range self.Pilots {
 		if pilot.DockedStatus == hal.DOCKED {
@@ -0,0 +1,250 @@
+package ai
+
+import (
+	"fmt"
+
+	hal "../core"
+)
+
+// An OpponentModel watches one enemy player all game, building up a few features of how it plays,
+// and from those puts it in one of a few broad classes. Decisions that used to rely only on fixed
+// distances and turn counts can then also consider who they're up against.
+
+type OpponentStyle int
+
+const (
+	UNKNOWN_STYLE OpponentStyle = iota
+	EXPANDER								// Docks early and grows; the normal case
+	TURTLE									// Docks early, keeps nearly everything docked, rarely attacks
+	RUSHER									// Doesn't dock early; comes straight for us
+	HARASSER								// Sends raiders at our docked ships, or lone ships to distract us
+)
+
+func (self OpponentStyle) String() string {
+	switch self {
+		case EXPANDER: return "expander"
+		case TURTLE: return "turtle"
+		case RUSHER: return "rusher"
+		case HARASSER: return "harasser"
+	}
+	return "unknown"
+}
+
+const (
+	STYLE_MIN_TURNS = 5						// Don't classify anyone before this turn
+	RUSHER_NO_DOCK_TURNS = 8				// Not docked by this turn means rusher (unless far from us)
+	RUSHER_DIST = 100						// ...where "far" is further than this
+	RUSHER_WATCH_TURNS = 50					// Only track how close it comes to us before this turn
+	TURTLE_UNDOCKED = 0.25					// Fraction of ships undocked, at or below which it's a turtle...
+	TURTLE_AGGRESSION = 0.02				// ...unless it's at least this aggressive
+	HARASSER_AGGRESSION = 0.1				// Fraction of undocked ship-turns spent raiding
+	HARASSER_DISTRACTION = 0.2				// Fraction of turns with a lone distractor out
+	DISTRACTOR_LONE_DIST = 30				// No friend of the ship's closer than this
+	DISTRACTOR_PLANET_DIST = 30				// Not this close to any planet its owner has
+	DISTRACTOR_ATTENTION = 2				// Our undocked ships within DISTRACTOR_LONE_DIST needed
+)
+
+type OpponentModel struct {
+	Pid						int
+	FirstDockTurn			int				// -1 means never seen docking
+	Style					OpponentStyle
+	StyleTurn				int				// Turn the style last changed
+
+	turns					int				// Turns observed with the player alive
+	undocked_fraction_sum	float64
+	undocked_ship_turns		int
+	raid_ship_turns			int				// Undocked ship-turns spent heading for our docked ships
+	distractor_turns		int				// Turns with at least 1 lone distractor ship out
+	min_dist_to_us			float64			// Closest any of its ships has come to our ships (early on)
+}
+
+func NewOpponentModel(pid int) *OpponentModel {
+	return &OpponentModel{
+		Pid: pid,
+		FirstDockTurn: -1,
+		min_dist_to_us: 9999,
+	}
+}
+
+func (self *OpponentModel) String() string {
+	return fmt.Sprintf("Opponent %d: %v (first dock %d, undocked %.2f, aggression %.2f, distraction %.2f)",
+		self.Pid, self.Style, self.FirstDockTurn, self.FractionUndocked(), self.Aggression(), self.Distraction())
+}
+
+func (self *OpponentModel) FractionUndocked() float64 {
+	if self.turns == 0 {
+		return 1
+	}
+	return self.undocked_fraction_sum / float64(self.turns)
+}
+
+func (self *OpponentModel) Aggression() float64 {
+	if self.undocked_ship_turns == 0 {
+		return 0
+	}
+	return float64(self.raid_ship_turns) / float64(self.undocked_ship_turns)
+}
+
+func (self *OpponentModel) Distraction() float64 {
+	if self.turns == 0 {
+		return 0
+	}
+	return float64(self.distractor_turns) / float64(self.turns)
+}
+
+func (self *OpponentModel) Is(style OpponentStyle) bool {
+	return self.Style == style
+}
+
+// --------------------------------------------
+
+func (self *Overmind) UpdateOpponents() {
+
+	if self.Opponents == nil {
+		self.Opponents = make(map[int]*OpponentModel)
+	}
+
+	for pid := 0; pid < self.Game.InitialPlayers(); pid++ {
+
+		if pid == self.Game.Pid() {
+			continue
+		}
+
+		model, ok := self.Opponents[pid]
+		if ok == false {
+			model = NewOpponentModel(pid)
+			self.Opponents[pid] = model
+		}
+
+		ships := self.Game.ShipsOwnedBy(pid)
+
+		if len(ships) == 0 {
+			continue
+		}
+
+		model.turns++
+
+		undocked := 0
+		distractor := false
+
+		for _, ship := range ships {
+
+			if ship.DockedStatus != hal.UNDOCKED {
+				if model.FirstDockTurn == -1 {
+					model.FirstDockTurn = self.Game.Turn()
+				}
+				continue
+			}
+
+			undocked++
+
+			if self.HeadingForUs(ship) {
+				model.raid_ship_turns++
+			}
+
+			if self.IsDistractor(ship) {
+				distractor = true
+			}
+
+			if self.Game.Turn() < RUSHER_WATCH_TURNS {
+				nearest := self.Game.NearestShips(ship.X, ship.Y, 1, func(other *hal.Ship) bool {
+					return other.Owner == self.Game.Pid()
+				})
+				if len(nearest) > 0 {
+					model.min_dist_to_us = hal.MinFloat(model.min_dist_to_us, ship.Dist(nearest[0]))
+				}
+			}
+		}
+
+		model.undocked_ship_turns += undocked
+		model.undocked_fraction_sum += float64(undocked) / float64(len(ships))
+
+		if distractor {
+			model.distractor_turns++
+		}
+
+		old_style := model.Style
+		model.Style = model.classify(self.Game.Turn())
+
+		if model.Style != old_style {
+			model.StyleTurn = self.Game.Turn()
+			self.Logger().With("opponent", model.Pid).With("style", model.Style).With("first_dock", model.FirstDockTurn).
+				With("undocked", model.FractionUndocked()).With("aggression", model.Aggression()).With("distraction", model.Distraction()).
+				Info("Opponent %d is now: %v", model.Pid, model.Style)
+		}
+	}
+}
+
+func (self *OpponentModel) classify(turn int) OpponentStyle {
+
+	if turn < STYLE_MIN_TURNS {
+		return UNKNOWN_STYLE
+	}
+
+	if self.FirstDockTurn == -1 || self.FirstDockTurn >= RUSHER_NO_DOCK_TURNS {
+		if turn >= RUSHER_NO_DOCK_TURNS && self.min_dist_to_us < RUSHER_DIST {
+			if self.FirstDockTurn == -1 || self.Aggression() >= HARASSER_AGGRESSION {
+				return RUSHER
+			}
+		}
+	}
+
+	if self.Aggression() >= HARASSER_AGGRESSION || self.Distraction() >= HARASSER_DISTRACTION {
+		return HARASSER
+	}
+
+	if self.FirstDockTurn != -1 && self.FractionUndocked() <= TURTLE_UNDOCKED && self.Aggression() < TURTLE_AGGRESSION {
+		return TURTLE
+	}
+
+	if self.FirstDockTurn != -1 {
+		return EXPANDER
+	}
+
+	return UNKNOWN_STYLE
+}
+
+func (self *Overmind) Opponent(pid int) *OpponentModel {
+
+	// Never nil; players we've never seen are unknown.
+
+	if model, ok := self.Opponents[pid]; ok {
+		return model
+	}
+	return NewOpponentModel(pid)
+}
+
+// --------------------------------------------
+
+func (self *Overmind) IsDistractor(ship *hal.Ship) bool {
+
+	// A lone undocked enemy ship, away from its own planets, drawing the attention of several of ours.
+	// See the README for the sort of thing this is about.
+
+	if ship.DockedStatus != hal.UNDOCKED || ship.Owner == self.Game.Pid() {
+		return false
+	}
+
+	attention := 0
+
+	for _, other := range self.Game.ShipsWithin(ship.X, ship.Y, DISTRACTOR_LONE_DIST) {
+		if other.Owner == ship.Owner && other != ship {
+			return false
+		}
+		if other.Owner == self.Game.Pid() && other.DockedStatus == hal.UNDOCKED {
+			attention++
+		}
+	}
+
+	if attention < DISTRACTOR_ATTENTION {
+		return false
+	}
+
+	for _, planet := range self.Game.PlanetsOwnedBy(ship.Owner) {
+		if ship.ApproachDist(planet) < DISTRACTOR_PLANET_DIST {
+			return false
+		}
+	}
+
+	return true
+}
@@ -8,6 +8,11 @@ import (
 	pil "../pilot"
 )
 
+const (
+	RAIDER_VALUE = 1.2
+	RAIDER_MAX_ETA = 6
+)
+
 type Problem struct {
 	Entity		hal.Entity
 	Value		float64
@@ -42,6 +47,7 @@ func (self *Overmind) ChooseTargets() {
 
 		if pilot.Target.Type() != hal.NOTHING {			// Because our target wasn't reset for some reason.
 			pilot.MessageWhileLocked()
+			pilot.Trace("problem").With("kept", pilot.Target)
 			continue
 		}
 
@@ -55,6 +61,9 @@ func (self *Overmind) ChooseTargets() {
 		pilot.Target = all_problems[0].Entity
 		pilot.Message = all_problems[0].Message
 
+		pilot.Trace("problem").With("target", all_problems[0].Entity).With("value", all_problems[0].Value).
+			With("need", all_problems[0].Need).With("message", all_problems[0].Message).With("problems", len(all_problems))
+
 		all_problems[0].Need--							// We could consider only doing this for non-doomed pilots. Hmm. They still do damage though.
 		if all_problems[0].Need <= 0 {
 			all_problems = all_problems[1:]
@@ -79,13 +88,24 @@ func (self *Overmind) AllProblems() []*Problem {
 
 	for _, ship := range self.Game.EnemyShips() {
 
-		if ship.Doomed == false {		// Skip the ship (as an assassination target) if we expect it to die at time 0.
+		if ship.DoomChance < hal.RISK_TARGET_IGNORE {		// Skip the ship (as an assassination target) if we expect it to die at time 0.
 			problem := &Problem{		// Note that we may end up targetting it as a planet's secondary target.
 				Entity: ship,
 				Value: 1.0,
 				Need: 1,
 				Message: pil.MSG_ASSASSINATE,
 			}
+
+			// Raiders on their way to our docked ships are worth meeting before they get there...
+
+			if intent := self.Game.Intent(ship); intent.Kind == hal.INTENT_DOCKED_SHIP && self.HeadingForUs(ship) && intent.Eta <= RAIDER_MAX_ETA {
+				problem.Value = RAIDER_VALUE
+			}
+
+			// A ship that might die anyway is worth less...
+
+			problem.Value *= 1 - ship.DoomChance
+
 			all_problems = append(all_problems, problem)
 		}
 	}
@@ -112,7 +132,7 @@ func (self *Overmind) PlanetProblems(planet *hal.Planet) []*Problem {
 				Entity: planet,
 				Value: value,
 				Need: capture_strength,
-				Message: planet.Id,
+				Message: pil.IdMessage(planet.Id),
 			})
 		}
 
@@ -120,13 +140,19 @@ func (self *Overmind) PlanetProblems(planet *hal.Planet) []*Problem {
 
 		for _, enemy := range enemies {
 
-			// We can't skip Doomed targets here because we need to actually doom them before we dock.
+			// We can't skip doomed targets here because we need to actually doom them before we dock.
+			// A harasser's lone distractor only gets 1 ship though; that's the whole point of it.
+
+			need := 2
+			if self.Opponent(enemy.Owner).Is(HARASSER) && self.IsDistractor(enemy) {
+				need = 1
+			}
 
 			ret = append(ret, &Problem{
 				Entity: enemy,
 				Value: 1.0,
-				Need: 2,
-				Message: planet.Id,
+				Need: need,
+				Message: pil.IdMessage(planet.Id),
 			})
 		}
 	}
@@ -141,7 +167,7 @@ func (self *Overmind) RushProblems() []*Problem {
 	var helpable_docked_ships []*hal.Ship
 
 	for _, ship := range self.Game.MyShips() {
-		if ship.DockedStatus != hal.UNDOCKED && ship.Doomed == false {
+		if ship.DockedStatus != hal.UNDOCKED && ship.DoomChance < hal.RISK_TARGET_IGNORE {
 			helpable_docked_ships = append(helpable_docked_ships, ship)
 		}
 	}
@@ -150,11 +176,13 @@ func (self *Overmind) RushProblems() []*Problem {
 
 		for _, ship := range helpable_docked_ships {
 
+			// Those that might be attacked this turn come first...
+
 			problem := &Problem{
 				Entity: ship,
-				Value: 1.0,
+				Value: 1.0 + ship.DeathChance,
 				Need: 1,
-				Message: ship.Id,
+				Message: pil.IdMessage(ship.Id),
 			}
 			problems = append(problems, problem)
 		}
@@ -176,13 +204,13 @@ func (self *Overmind) RushProblems() []*Problem {
 
 			if ship.DockedStatus != hal.UNDOCKED || some_are_docked == false {
 
-				if ship.Doomed == false {
+				if ship.DoomChance < hal.RISK_TARGET_IGNORE {
 
 					problem := &Problem{
 						Entity: ship,
 						Value: 1.0,
 						Need: 1,
-						Message: ship.Id,
+						Message: pil.IdMessage(ship.Id),
 					}
 
 					problems = append(problems, problem)
@@ -229,6 +257,8 @@ func (self *Overmind) OptimisePilots() {
 				if swap_dist < total_dist {
 					pilot_a.Target, pilot_b.Target = pilot_b.Target, pilot_a.Target
 					pilot_a.Message, pilot_b.Message = pilot_b.Message, pilot_a.Message
+					pilot_a.Trace("swap").With("with", pilot_b.Id).With("target", pilot_a.Target)
+					pilot_b.Trace("swap").With("with", pilot_a.Id).With("target", pilot_b.Target)
 				}
 			}
 		}
@@ -0,0 +1,319 @@
+package main
+
+// Walks replays (files, or directories of .hlt files) and measures what each player did: ships and planets over
+// time, first dock, kills and losses, whether it rushed, and (from our angle messages, where they can be trusted)
+// how many turns our bot spent rushing or in coward mode. Writes one CSV row per player per replay, and prints a
+// summary table of our bot, one line per version, so versions can be compared by numbers rather than by replay
+// names.
+//
+//     analyse -csv analysis.csv "../reference replays"
+//     analyse -series series.csv -me "" replays/           (per-turn counts too; summarise every player by name)
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	hal "../../core"
+	pil "../../pilot"
+	rep "../../replay"
+)
+
+var CHECKPOINTS = []int{25, 50, 100, 200}
+
+func main() {
+
+	csv_file := flag.String("csv", "analysis.csv", "CSV output, one row per player per replay (- for stdout)")
+	series_file := flag.String("series", "", "optional CSV of ships and planets per player per turn")
+	me := flag.String("me", "fohristiwhirl", "name prefix of our bot, summarised by version (\"\" to summarise every name)")
+
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: analyse [flags] replay_dir_or_file...\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	filenames, err := find_replays(flag.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	var rows []*Row
+
+	for _, filename := range filenames {
+		replay, err := rep.Load(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)			// Skip it, but carry on
+			continue
+		}
+		rows = append(rows, analyse(filename, replay, *me)...)
+	}
+
+	err = write_csv(*csv_file, rows, row_records)
+	if err == nil && *series_file != "" {
+		err = write_csv(*series_file, rows, series_records)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%d replays, %d player rows\n\n", len(filenames), len(rows))
+	print_summary(os.Stdout, rows, *me == "")
+}
+
+func find_replays(args []string) ([]string, error) {
+
+	var ret []string
+
+	for _, arg := range args {
+		err := filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() == false && (path == arg || strings.ToLower(filepath.Ext(path)) == ".hlt") {
+				ret = append(ret, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// ------------------------------------------------------------------------------------------------
+
+func write_csv(filename string, rows []*Row, records func(rows []*Row) [][]string) error {
+
+	var out io.Writer = os.Stdout
+
+	if filename != "-" {
+		outfile, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+		defer outfile.Close()
+		out = outfile
+	}
+
+	w := csv.NewWriter(out)
+	w.WriteAll(records(rows))
+	return w.Error()
+}
+
+func row_records(rows []*Row) [][]string {
+
+	header := []string{"file", "version", "players", "pid", "name", "rank", "last_frame_alive", "total_ships"}
+	for _, turn := range CHECKPOINTS {
+		header = append(header, fmt.Sprintf("ships_t%d", turn))
+	}
+	header = append(header, "ships_peak", "ships_end")
+	for _, turn := range CHECKPOINTS {
+		header = append(header, fmt.Sprintf("planets_t%d", turn))
+	}
+	header = append(header, "planets_peak", "planets_end", "first_dock", "kills", "losses", "rushed",
+		"message_turns", "ambiguous_turns", "rush_turns", "coward_turns")
+
+	ret := [][]string{header}
+
+	for _, row := range rows {
+
+		record := []string{row.File, row.Version, itoa(row.Players), itoa(row.Pid), row.Name, itoa(row.Rank),
+			itoa(row.LastFrameAlive), itoa(row.TotalShips)}
+
+		for _, turn := range CHECKPOINTS {
+			record = append(record, at_turn(row.Ships, turn))
+		}
+		record = append(record, itoa(peak(row.Ships)), itoa(row.Ships[len(row.Ships) - 1]))
+
+		for _, turn := range CHECKPOINTS {
+			record = append(record, at_turn(row.Planets, turn))
+		}
+		record = append(record, itoa(peak(row.Planets)), itoa(row.Planets[len(row.Planets) - 1]))
+
+		rush_turns, coward_turns := "", ""					// Left blank when the messages can't be trusted
+		if row.MessagesReliable() {
+			rush_turns, coward_turns = itoa(row.RushTurns), itoa(row.CowardTurns)
+		}
+
+		record = append(record, itoa(row.FirstDock), strconv.FormatFloat(row.Kills, 'f', 1, 64), itoa(row.Losses),
+			strconv.FormatBool(row.Rushed), itoa(row.MessageTurns), itoa(row.AmbiguousTurns), rush_turns, coward_turns)
+
+		ret = append(ret, record)
+	}
+
+	return ret
+}
+
+func series_records(rows []*Row) [][]string {
+
+	ret := [][]string{[]string{"file", "pid", "name", "turn", "ships", "planets"}}
+
+	for _, row := range rows {
+		for turn := range row.Ships {
+			ret = append(ret, []string{row.File, itoa(row.Pid), row.Name, itoa(turn), itoa(row.Ships[turn]), itoa(row.Planets[turn])})
+		}
+	}
+
+	return ret
+}
+
+func itoa(n int) string {
+	return strconv.Itoa(n)
+}
+
+func at_turn(counts []int, turn int) string {		// Blank if the game was over by then
+	if turn >= len(counts) {
+		return ""
+	}
+	return itoa(counts[turn])
+}
+
+func peak(counts []int) int {
+	ret := 0
+	for _, n := range counts {
+		ret = hal.Max(ret, n)
+	}
+	return ret
+}
+
+// ------------------------------------------------------------------------------------------------
+
+type summary struct {
+	key				string
+	games			int
+	games_4p		int
+	wins			int
+	rank_sum		int
+	ships_100		[]int					// Only games that lasted that long
+	planets_peak	int
+	first_docks		[]int					// Only games where it docked
+	kills			float64
+	losses			int
+	rushes			int
+	message_games	int						// Games whose messages can be trusted
+	rush_turns		int
+	coward_turns	int
+}
+
+func print_summary(w io.Writer, rows []*Row, by_name bool) {
+
+	// Our bot by version; or, if by_name, every player by name.
+
+	summaries := make(map[string]*summary)
+	var keys []string
+
+	for _, row := range rows {
+
+		key := row.Version
+		if by_name {
+			key = row.Name
+		}
+		if key == "" {
+			continue
+		}
+
+		s := summaries[key]
+		if s == nil {
+			s = &summary{key: key}
+			summaries[key] = s
+			keys = append(keys, key)
+		}
+
+		s.games++
+		if row.Players > 2 {
+			s.games_4p++
+		}
+		if row.Rank == 1 {
+			s.wins++
+		}
+		s.rank_sum += row.Rank
+		if len(row.Ships) > 100 {
+			s.ships_100 = append(s.ships_100, row.Ships[100])
+		}
+		s.planets_peak += peak(row.Planets)
+		if row.FirstDock >= 0 {
+			s.first_docks = append(s.first_docks, row.FirstDock)
+		}
+		s.kills += row.Kills
+		s.losses += row.Losses
+		if row.Rushed {
+			s.rushes++
+		}
+		if row.MessagesReliable() {
+			s.message_games++
+			s.rush_turns += row.RushTurns
+			s.coward_turns += row.CowardTurns
+		}
+	}
+
+	// Versions in numeric order (v9 before v10), names alphabetically...
+
+	sort.Slice(keys, func(a, b int) bool {
+		na, erra := strconv.Atoi(strings.TrimPrefix(keys[a], "v"))
+		nb, errb := strconv.Atoi(strings.TrimPrefix(keys[b], "v"))
+		if erra == nil && errb == nil && na != nb {
+			return na < nb
+		}
+		return keys[a] < keys[b]
+	})
+
+	fmt.Fprintf(w, "%-24s %5s %4s %5s %5s %8s %8s %6s %6s %6s %6s %6s %7s %8s\n",
+		"", "games", "4p", "wins", "rank", "ships100", "planets", "dock", "kills", "losses", "k/l", "rushed", "rush t", "coward t")
+
+	for _, key := range keys {
+
+		s := summaries[key]
+		games := float64(s.games)
+
+		kl := "-"
+		if s.losses > 0 {
+			kl = fmt.Sprintf("%.2f", s.kills / float64(s.losses))
+		}
+
+		rush_turns, coward_turns := "-", "-"
+		if s.message_games > 0 {
+			rush_turns = fmt.Sprintf("%.1f", float64(s.rush_turns) / float64(s.message_games))
+			coward_turns = fmt.Sprintf("%.1f", float64(s.coward_turns) / float64(s.message_games))
+		}
+
+		name := key
+		if len(name) > 24 {
+			name = name[:24]
+		}
+
+		fmt.Fprintf(w, "%-24s %5d %4d %5d %5.2f %8s %8.1f %6s %6.1f %6.1f %6s %5.0f%% %7s %8s\n",
+			name, s.games, s.games_4p, s.wins, float64(s.rank_sum) / games, mean(s.ships_100), float64(s.planets_peak) / games,
+			mean(s.first_docks), s.kills / games, float64(s.losses) / games, kl, 100 * float64(s.rushes) / games,
+			rush_turns, coward_turns)
+	}
+
+	fmt.Fprintf(w, "\nPer game averages. ships100: ships at turn 100 (games that got there); planets: peak held; dock: first docking turn;\n")
+	fmt.Fprintf(w, "rushed: games where it didn't dock early and went for an enemy; rush t, coward t: turns, from our messages\n")
+	fmt.Fprintf(w, "(rush t counts turns the GA moved ships, or most ships chased ships), only over games where no message above %d\n", pil.MSG_MAX_ID)
+	fmt.Fprintf(w, "could have been a ship ID (\"-\" if there were none).\n")
+}
+
+func mean(values []int) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	sum := 0
+	for _, v := range values {
+		sum += v
+	}
+	return fmt.Sprintf("%.1f", float64(sum) / float64(len(values)))
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	ai "../../ai"
+	hal "../../core"
+	pil "../../pilot"
+	rep "../../replay"
+)
+
+// What one player did in one replay. Everything comes from the frames and events, except the last group,
+// which is inferred from angle messages and so only means anything for our bot (when MessageTurns > 0).
+// Older versions sent ship IDs above MSG_MAX_ID as messages, which read as codes; so if any message above
+// MSG_MAX_ID could have been naming a ship, RushTurns and CowardTurns can't be trusted (see MessagesReliable()).
+
+type Row struct {
+	File			string
+	Version			string					// Our bot's version, e.g. "v62"; "" for everyone else
+	Players			int
+	Pid				int
+	Name			string
+	Rank			int						// 0 if the replay has no stats
+	LastFrameAlive	int
+	TotalShips		int						// Cumulative, counted from the frames
+	Ships			[]int					// Frame --> count
+	Planets			[]int					// Frame --> count
+	FirstDock		int						// First frame with a ship docking, or -1
+	Kills			float64					// Shared between the players who shot the ship that turn
+	Losses			int
+	Rushed			bool					// By the rule our opponent model uses (see ai/opponents.go)
+
+	MessageTurns	int						// Turns on which the player sent any messages
+	RushTurns		int						// Turns on which the GA moved ships, or most ID messages named ships
+	CowardTurns		int						// Turns on which any ship sent MSG_COWARD
+	AmbiguousTurns	int						// Turns on which a message above MSG_MAX_ID could be a ship ID
+}
+
+func (self *Row) MessagesReliable() bool {
+	return self.MessageTurns > 0 && self.AmbiguousTurns == 0
+}
+
+var version_regex = regexp.MustCompile(`(\d+)`)
+
+func analyse(filename string, replay *rep.Replay, me string) []*Row {
+
+	var ret []*Row
+
+	for pid := 0; pid < replay.NumPlayers; pid++ {
+
+		row := &Row{
+			File: filepath.Base(filename),
+			Players: replay.NumPlayers,
+			Pid: pid,
+			FirstDock: -1,
+		}
+
+		if pid < len(replay.PlayerNames) {
+			row.Name = replay.PlayerNames[pid]
+		}
+
+		if me != "" && strings.HasPrefix(strings.ToLower(row.Name), strings.ToLower(me)) {
+			row.Version = bot_version(row.Name, row.File)
+		}
+
+		if stats, ok := replay.Stats[pid]; ok && stats != nil {
+			row.Rank = stats.Rank
+			row.LastFrameAlive = stats.LastFrameAlive
+		}
+
+		ret = append(ret, row)
+	}
+
+	count_ships_and_planets(replay, ret)
+	count_kills(replay, ret)
+	detect_rushes(replay, ret)
+	read_messages(replay, ret)
+
+	return ret
+}
+
+func bot_version(name, filename string) string {
+
+	// From the name if it has a number in it (e.g. "fohristiwhirl v62", "Fohristiwhirl 91 dev"), else from the
+	// file name, as in the reference replays (e.g. "v62 - game v dhallstr.hlt").
+
+	for _, s := range []string{name, filename} {
+		if match := version_regex.FindString(s); match != "" {
+			return "v" + match
+		}
+	}
+	return "v?"
+}
+
+// ------------------------------------------------------------------------------------------------
+
+func count_ships_and_planets(replay *rep.Replay, rows []*Row) {
+
+	seen := make(map[int]bool)
+
+	for n, frame := range replay.Frames {
+
+		for _, row := range rows {
+
+			row.Ships = append(row.Ships, len(frame.Ships[row.Pid]))
+
+			planets := 0
+			for _, planet := range frame.Planets {
+				if planet.Owner != nil && *planet.Owner == row.Pid {
+					planets++
+				}
+			}
+			row.Planets = append(row.Planets, planets)
+
+			for sid, ship := range frame.Ships[row.Pid] {
+
+				if seen[sid] == false {
+					seen[sid] = true
+					row.TotalShips++
+				}
+
+				if ship.Docking.Status != "undocked" && row.FirstDock == -1 {
+					row.FirstDock = n
+				}
+			}
+
+			// Anything gone by the next frame was lost, whether shot, crashed, or caught in an explosion...
+
+			if n + 1 < len(replay.Frames) {
+				for sid := range frame.Ships[row.Pid] {
+					if _, ok := replay.Frames[n + 1].Ships[row.Pid][sid]; ok == false {
+						row.Losses++
+					}
+				}
+			}
+		}
+	}
+}
+
+func count_kills(replay *rep.Replay, rows []*Row) {
+
+	// A destroyed ship is credited to the players who attacked it that turn, shared equally. Ships lost to
+	// collisions aren't anyone's kill.
+
+	for _, frame := range replay.Frames {
+
+		attackers := make(map[int]map[int]bool)			// Ship ID --> set of attacking players
+
+		for _, event := range frame.Events {
+			if event.Event != "attack" {
+				continue
+			}
+			for _, target := range event.Targets {
+				if target.Type == "ship" && target.Owner != event.Entity.Owner {
+					if attackers[target.Id] == nil {
+						attackers[target.Id] = make(map[int]bool)
+					}
+					attackers[target.Id][event.Entity.Owner] = true
+				}
+			}
+		}
+
+		for _, event := range frame.Events {
+			if event.Event != "destroyed" || event.Entity.Type != "ship" {
+				continue
+			}
+			for pid := range attackers[event.Entity.Id] {
+				if pid >= 0 && pid < len(rows) {
+					rows[pid].Kills += 1 / float64(len(attackers[event.Entity.Id]))
+				}
+			}
+		}
+	}
+}
+
+func detect_rushes(replay *rep.Replay, rows []*Row) {
+
+	// As ai/opponents.go judges a rusher: not docked by RUSHER_NO_DOCK_TURNS, and came within RUSHER_DIST of an
+	// enemy in the first RUSHER_WATCH_TURNS.
+
+	for _, row := range rows {
+
+		if len(replay.Frames) <= ai.RUSHER_NO_DOCK_TURNS {
+			continue
+		}
+
+		if row.FirstDock != -1 && row.FirstDock < ai.RUSHER_NO_DOCK_TURNS {
+			continue
+		}
+
+		min_dist := 999999.9
+
+		for n := 0; n < len(replay.Frames) && n < ai.RUSHER_WATCH_TURNS; n++ {
+			frame := replay.Frames[n]
+			for _, ship := range frame.Ships[row.Pid] {
+				if ship.Docking.Status != "undocked" {
+					continue
+				}
+				for pid, player_ships := range frame.Ships {
+					if pid == row.Pid {
+						continue
+					}
+					for _, other := range player_ships {
+						min_dist = hal.MinFloat(min_dist, hal.Dist(ship.X, ship.Y, other.X, other.Y))
+					}
+				}
+			}
+		}
+
+		row.Rushed = min_dist < ai.RUSHER_DIST
+	}
+}
+
+func read_messages(replay *rep.Replay, rows []*Row) {
+
+	for turn := 0; turn < replay.Turns(); turn++ {
+
+		for _, row := range rows {
+
+			sent, coward, ambiguous, ga := false, false, false, false
+			ids, ship_ids := 0, 0
+
+			for _, move := range replay.PlayerMoves(turn, row.Pid) {
+
+				message := move.Message()
+
+				if message < 0 {
+					continue
+				}
+
+				sent = true
+
+				if message == pil.MSG_COWARD {
+					coward = true
+				}
+
+				if message == pil.MSG_SECRET_SAUCE || message == pil.MSG_PERFECT_SAUCE || message == pil.MSG_GLOBAL_SAUCE {
+					ga = true							// The GA only runs in rush fights
+				}
+
+				if message <= pil.MSG_MAX_ID {
+					ids++
+					if planet_ok, ship_ok := replay.ResolveMessageID(turn, row.Pid, move, message); ship_ok && planet_ok == false {
+						ship_ids++
+					}
+				} else if _, ship_ok := replay.ResolveMessageID(turn, row.Pid, move, message); ship_ok {
+					ambiguous = true
+				}
+			}
+
+			if sent {
+				row.MessageTurns++
+			}
+			if coward {
+				row.CowardTurns++
+			}
+			if ambiguous {
+				row.AmbiguousTurns++
+			}
+			if ga || (ids > 0 && ship_ids * 2 > ids) {
+				row.RushTurns++
+			}
+		}
+	}
+}
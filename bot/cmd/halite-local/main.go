@@ -0,0 +1,98 @@
+package main
+
+// Runs a local game between 2 or 4 bots, without the official environment. Each bot is either
+// a command line (e.g. "./MyBot -conservative") or "builtin" followed by the usual bot flags,
+// which runs an Overmind in this very process. With -live, the game is drawn in the terminal
+// each turn (see render/terminal.go), messages and all.
+//
+//     halite-local -seed 42 -replay test.hlt "builtin" "builtin -conservative"
+//     halite-local -live "builtin" "./MyBot"
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	hal "../../core"
+	gen "../../genetic"
+	local "../../local"
+	ren "../../render"
+)
+
+func main() {
+
+	seed := flag.Int64("seed", 0, "map seed (0 for time)")
+	width := flag.Int("width", 0, "map width (0 for random)")
+	turns := flag.Int("turns", local.MAX_TURNS, "maximum turns")
+	timeout := flag.Int("timeout", 2000, "turn timeout in ms for subprocess bots (0 for none)")
+	replay := flag.String("replay", "", "replay file (default: replay-<seed>.hlt)")
+	dir := flag.String("dir", "", "working directory for subprocess bots")
+	live := flag.Bool("live", false, "draw the game in the terminal each turn")
+
+	flag.Parse()
+
+	bot_args := flag.Args()
+
+	if len(bot_args) != 2 && len(bot_args) != 4 {
+		fmt.Fprintf(os.Stderr, "Usage: halite-local [flags] bot1 bot2 [bot3 bot4]\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano() % 1000000000
+	}
+
+	if *replay == "" {
+		*replay = fmt.Sprintf("replay-%d.hlt", *seed)
+	}
+
+	match := &local.Match{
+		Seed: *seed,
+		Width: *width,
+		MaxTurns: *turns,
+		TurnTimeout: time.Duration(*timeout) * time.Millisecond,
+		ReplayFile: *replay,
+	}
+
+	if *live {
+		match.OnTurn = draw_live
+	}
+
+	for _, arg := range bot_args {
+		player, err := local.NewPlayer(arg, *dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		match.Players = append(match.Players, player)
+	}
+
+	result := match.Run()
+
+	fmt.Printf("Seed: %d\n", *seed)
+	fmt.Print(result)
+	fmt.Printf("Replay: %s\n", *replay)
+}
+
+func draw_live(sim *gen.TurnSim, result *local.Result) {
+
+	// The sim's Game doesn't know the history, so the cumulative counts come from the result instead.
+	// Every bot's messages are shown, decoded from what it sent.
+
+	view := ren.NewTermView(sim.Game(0))
+
+	for _, player := range result.Players {
+		view.Stats[player.Pid] = &ren.TermStats{
+			Name: player.Name,
+			Cumulative: player.TotalShips,
+			TurnTime: player.LastTurnTime,
+		}
+		for sid, message := range hal.CommandMessages(player.LastCommands) {
+			view.Messages[sid] = message
+		}
+	}
+
+	view.Draw(os.Stdout)
+}
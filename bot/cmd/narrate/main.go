@@ -0,0 +1,317 @@
+package main
+
+// Reads a replay and decodes the messages our bot hides in its thrust angles (see pilot/messages.go for
+// the registry) back into what each ship was up to, turn by turn; then prints each ship's story as a
+// timeline, with runs of the same intent collapsed:
+//
+//     ship 12: t3-8 planet 5 → t9-14 assassinate ship 40 → t15-20 coward
+//
+// Messages that are IDs could be a planet or (when rushing) a ship; whichever with that ID the sender is
+// near or heading for is taken, and if both fit, both are given. Codes that mean chasing an enemy ship
+// don't say which, so the target is guessed: the nearest enemy ship roughly in the direction of travel.
+// Docking and undocking are shown too.
+//
+//     narrate -pid 0 -from 0 -to 50 replay.hlt
+//     narrate -list -ship 12 replay.hlt          (one line per turn instead)
+//     narrate -registry                          (the message codes)
+//
+// Given the trace file our bot wrote during the game (see core/trace.go), each traced ship's record for a turn is
+// shown under that turn's line, so what the ship was told to do can be read next to what it decided and why:
+//
+//     narrate -trace trace0.jsonl -ship 12 replay.hlt
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	hal "../../core"
+	pil "../../pilot"
+	rep "../../replay"
+)
+
+const (
+	GUESS_RANGE = 60						// How far away a guessed ship target can be (see also replay/messages.go)
+)
+
+type beat struct {
+	turn			int
+	text			string
+	record			*hal.TraceRecord			// nil unless the ship was traced that turn
+}
+
+type trace_key struct {
+	pid				int
+	turn			int
+	ship			int
+}
+
+func main() {
+
+	pid := flag.Int("pid", -1, "player (default: every player that sent messages)")
+	sid := flag.Int("ship", -1, "only this ship")
+	from := flag.Int("from", 0, "first turn")
+	to := flag.Int("to", -1, "last turn (default: the end)")
+	list := flag.Bool("list", false, "one line per ship per turn, not timelines")
+	registry := flag.Bool("registry", false, "print the message registry and exit")
+	trace := flag.String("trace", "", "trace file written by the bot, to show under each turn (implies -list)")
+
+	flag.Parse()
+
+	if *registry {
+		fmt.Printf("  0-%d  id: the planet (or ship, when rushing) the ship's problem is about\n", pil.MSG_MAX_ID)
+		for _, info := range pil.AllMessages() {
+			fmt.Printf("  %5d  %s: %s\n", info.Code, info.Name, info.Meaning)
+		}
+		return
+	}
+
+	if flag.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: narrate [flags] replay.hlt\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	replay, err := rep.Load(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if *to < 0 || *to >= replay.Turns() {
+		*to = replay.Turns() - 1
+	}
+
+	var records []*hal.TraceRecord
+	traced := make(map[trace_key]*hal.TraceRecord)
+
+	if *trace != "" {
+		records, err = hal.ReadTrace(*trace)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		for _, record := range records {
+			traced[trace_key{record.Pid, record.Turn, record.Ship}] = record
+		}
+		*list = true
+	}
+
+	for p := 0; p < replay.NumPlayers; p++ {
+
+		if *pid >= 0 && p != *pid {
+			continue
+		}
+
+		stories := make(map[int][]*beat)			// Ship ID --> beats
+		messages := 0
+
+		for turn := *from; turn <= *to; turn++ {
+			for _, move := range replay.PlayerMoves(turn, p) {
+				if *sid >= 0 && move.ShipId != *sid {
+					continue
+				}
+				if move.Message() >= 0 {
+					messages++
+				}
+				if text := describe(replay, turn, p, move); text != "" {
+					record := traced[trace_key{p, turn, move.ShipId}]
+					stories[move.ShipId] = append(stories[move.ShipId], &beat{turn, text, record})
+				}
+			}
+		}
+
+		// Traced ships that sent no order on some turn still get a line for it...
+
+		for _, record := range records {
+			if record.Pid != p || record.Turn < *from || record.Turn > *to || (*sid >= 0 && record.Ship != *sid) {
+				continue
+			}
+			if has_turn(stories[record.Ship], record.Turn) == false {
+				stories[record.Ship] = append(stories[record.Ship], &beat{record.Turn, "no order", record})
+			}
+		}
+
+		for _, story := range stories {
+			sort.SliceStable(story, func(a, b int) bool {
+				return story[a].turn < story[b].turn
+			})
+		}
+
+		if messages == 0 && *pid < 0 {
+			continue
+		}
+
+		name := ""
+		if p < len(replay.PlayerNames) {
+			name = replay.PlayerNames[p]
+		}
+		fmt.Printf("Player %d (%s), %d messages\n", p, name, messages)
+
+		var sids []int
+		for s := range stories {
+			sids = append(sids, s)
+		}
+		sort.Ints(sids)
+
+		for _, s := range sids {
+			if *list {
+				for _, b := range stories[s] {
+					fmt.Printf("  t %3d: ship %d: %s\n", b.turn, s, b.text)
+					if b.record != nil {
+						print_record(b.record)
+					}
+				}
+			} else {
+				fmt.Printf("  ship %d: %s\n", s, timeline(stories[s]))
+			}
+		}
+	}
+}
+
+func timeline(beats []*beat) string {
+
+	// Runs of the same text (on consecutive turns) become one part, e.g. "t3-8 planet 5".
+
+	var parts []string
+
+	for n := 0; n < len(beats); {
+
+		end := n
+		for end + 1 < len(beats) && beats[end + 1].text == beats[n].text && beats[end + 1].turn == beats[end].turn + 1 {
+			end++
+		}
+
+		if end == n {
+			parts = append(parts, fmt.Sprintf("t%d %s", beats[n].turn, beats[n].text))
+		} else {
+			parts = append(parts, fmt.Sprintf("t%d-%d %s", beats[n].turn, beats[end].turn, beats[n].text))
+		}
+
+		n = end + 1
+	}
+
+	return strings.Join(parts, " → ")
+}
+
+func has_turn(beats []*beat, turn int) bool {
+	for _, b := range beats {
+		if b.turn == turn {
+			return true
+		}
+	}
+	return false
+}
+
+func print_record(record *hal.TraceRecord) {
+
+	// One line per step, with the fields as the JSON they were written as; then the order the bot sent.
+
+	for _, step := range record.Steps {
+		var fields []string
+		for _, field := range step.Fields {
+			fields = append(fields, fmt.Sprintf("%s=%s", field.Key, field.Value))
+		}
+		fmt.Printf("           %s: %s\n", step.Stage, strings.Join(fields, " "))
+	}
+
+	fmt.Printf("           order: %s\n", record.Order)
+}
+
+// ------------------------------------------------------------------------------------------------
+
+func describe(replay *rep.Replay, turn, pid int, move *rep.Move) string {
+
+	frame := replay.Frames[turn]
+	ship := frame.Ships[pid][move.ShipId]
+
+	switch move.Type {
+	case "dock":
+		return fmt.Sprintf("dock planet %d", move.PlanetId)
+	case "undock":
+		return "undock"
+	}
+
+	message := move.Message()
+
+	if message < 0 {
+		return "move"
+	}
+
+	if ship == nil {								// Shouldn't happen
+		return pil.MessageName(message)
+	}
+
+	if message <= pil.MSG_MAX_ID {
+		return resolve_id(replay, turn, pid, move, message)
+	}
+
+	info, ok := pil.LookupMessage(message)
+
+	if ok == false {
+		return pil.MessageName(message)
+	}
+
+	if info.ShipTarget {
+		if target, ok := guess_ship_target(frame, ship, move); ok {
+			return fmt.Sprintf("%s ship %d", info.Name, target.Id)
+		}
+	}
+
+	return info.Name
+}
+
+func resolve_id(replay *rep.Replay, turn, pid int, move *rep.Move, id int) string {
+
+	planet_ok, ship_ok := replay.ResolveMessageID(turn, pid, move, id)
+
+	if planet_ok && ship_ok {
+		return fmt.Sprintf("planet or ship %d", id)
+	}
+	if ship_ok {
+		return fmt.Sprintf("ship %d", id)
+	}
+	if planet_ok {
+		return fmt.Sprintf("planet %d", id)
+	}
+	return fmt.Sprintf("id %d", id)
+}
+
+func guess_ship_target(frame *rep.Frame, ship *rep.FrameShip, move *rep.Move) (*rep.FrameShip, bool) {
+
+	var best *rep.FrameShip
+	best_dist := 999999.9
+
+	for owner, player_ships := range frame.Ships {
+
+		if owner == ship.Owner {
+			continue
+		}
+
+		for _, other := range player_ships {
+
+			d := hal.Dist(ship.X, ship.Y, other.X, other.Y)
+
+			if move.Magnitude == 0 {
+				if d > rep.MESSAGE_HOLD_RANGE {
+					continue
+				}
+			} else {
+				if d > GUESS_RANGE {
+					continue
+				}
+				if rep.AngleDiff(hal.Angle(ship.X, ship.Y, other.X, other.Y), move.Degrees()) > rep.MESSAGE_ANGLE {
+					continue
+				}
+			}
+
+			if d < best_dist || (d == best_dist && other.Id < best.Id) {
+				best, best_dist = other, d
+			}
+		}
+	}
+
+	return best, best != nil
+}
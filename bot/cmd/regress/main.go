@@ -0,0 +1,70 @@
+package main
+
+// Rewrites the golden outputs of the replay regression cases (see the regress package) from the current
+// bot, except on GA turns, whose output depends on timing. Check the diff before committing. The cases
+// themselves are run by the regress package's test:
+//
+//     go test ./regress                     (from the bot directory)
+//     regress -run "rush defense"           (update the goldens of those cases)
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	reg "../../regress"
+)
+
+func main() {
+
+	cases_file := flag.String("cases", reg.CasesFile(), "cases file")
+	run := flag.String("run", "", "only update cases whose name contains this")
+
+	flag.Parse()
+
+	cases, err := reg.LoadCases(*cases_file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	dir := filepath.Dir(*cases_file)
+
+	for _, c := range cases {
+
+		if strings.Contains(c.Name, *run) == false {
+			continue
+		}
+
+		start_time := time.Now()
+		outcome := c.Run(dir)
+		elapsed := time.Now().Sub(start_time).Truncate(time.Millisecond)
+
+		if outcome.Err != nil {
+			fmt.Printf("ERROR   %s (%v)\n", c.Name, outcome.Err)
+			continue
+		}
+
+		if outcome.GA {
+			fmt.Printf("SKIP    %s (GA output depends on timing)\n", c.Name)
+			continue
+		}
+
+		if outcome.Output == c.Golden {
+			fmt.Printf("same    %s (%v)\n", c.Name, elapsed)
+			continue
+		}
+
+		c.Golden = outcome.Output
+		fmt.Printf("UPDATE  %s (%v)\n", c.Name, elapsed)
+	}
+
+	err = reg.SaveCases(*cases_file, cases)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
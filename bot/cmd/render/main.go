@@ -0,0 +1,116 @@
+package main
+
+// Draws turns of a replay to PNG or SVG (see the render package), as seen by one player. With -bot, our
+// Overmind plays that player's seat on the replay, as the regress cases do, and what it was thinking each
+// turn is drawn on top. The usual bot flags can be given too. The output name is a format string taking
+// the turn number.
+//
+//     render -pid 0 -bot -from 40 -to 60 replay.hlt frames/turn%03d.png
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+
+	ai "../../ai"
+	pil "../../pilot"
+	rep "../../replay"
+	ren "../../render"
+)
+
+func main() {
+
+	config := new(ai.Config)
+	config.RegisterFlags(flag.CommandLine)
+
+	pid := flag.Int("pid", 0, "player whose view to draw")
+	from := flag.Int("from", 0, "first turn to draw")
+	to := flag.Int("to", -1, "last turn to draw (default: same as -from)")
+	bot := flag.Bool("bot", false, "run our bot in the player's seat and draw its thinking")
+	scale := flag.Float64("scale", 4, "pixels per unit of distance")
+	no_labels := flag.Bool("nolabels", false, "no text (SVG)")
+	no_ranges := flag.Bool("noranges", false, "no weapons range circles")
+
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "Usage: render [flags] replay.hlt output%%03d.png\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	if *to < *from {
+		*to = *from
+	}
+
+	if *to > *from && strings.Contains(flag.Arg(1), "%") == false {
+		fmt.Fprintf(os.Stderr, "output name needs a %%d (or similar) for the turn when drawing more than 1 turn\n")
+		os.Exit(1)
+	}
+
+	replay, err := rep.Load(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if *pid < 0 || *pid >= replay.NumPlayers {
+		fmt.Fprintf(os.Stderr, "no player %d in the replay\n", *pid)
+		os.Exit(1)
+	}
+
+	if *from < 0 || *to >= len(replay.Frames) {
+		fmt.Fprintf(os.Stderr, "turns %d-%d are not all in the replay (%d frames)\n", *from, *to, len(replay.Frames))
+		os.Exit(1)
+	}
+
+	playback := replay.NewPlayback(*pid)
+	game := playback.Game()
+
+	var overmind *ai.Overmind
+	if *bot {
+		overmind = ai.NewOvermind(game, config)
+	}
+
+	for playback.Turn() < *to {
+
+		playback.Next()
+
+		if overmind != nil {
+			if config.Timeseed == false {
+				rand.Seed(int64(game.Turn() + game.Width() + game.Pid()))		// As MyBot.go does.
+			}
+			overmind.Step()
+			game.ValidateOrders(config.NoMsg)									// As Game.Send() does.
+		}
+
+		if game.Turn() < *from {
+			continue
+		}
+
+		var pilots []*pil.Pilot
+		if overmind != nil {
+			pilots = overmind.Pilots
+		}
+
+		scene := ren.NewScene(game, pilots)
+		scene.Options.Scale = *scale
+		scene.Options.Labels = *no_labels == false
+		scene.Options.WeaponRanges = *no_ranges == false
+
+		filename := flag.Arg(1)
+		if strings.Contains(filename, "%") {
+			filename = fmt.Sprintf(filename, game.Turn())
+		}
+
+		err := scene.WriteFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("%s\n", filename)
+	}
+}
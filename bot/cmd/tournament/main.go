@@ -0,0 +1,269 @@
+package main
+
+// Runs many local games between bots and keeps TrueSkill ratings (see local/rating.go) in a leaderboard file, so
+// that whether a change is worth shipping can be measured before shipping it. Each entrant is "name=bot", where
+// the bot is as for halite-local: a command line, or "builtin" plus the usual flags. Every builtin entrant has its
+// own settings, navigation ones (-icd, -movers) included, so those can be compared too. Each round draws a map seed
+// and picks the entrants who have played least so far, then plays that map once with each rotation of the seats,
+// so no one is favoured by a spawn. Games run in parallel, but note that builtin bots take turns to think (they
+// share the global RNG), so subprocess bots gain the most from -parallel.
+//
+//     tournament -rounds 20 -sizes 2,4 -parallel 4 "bot=builtin" "conservative=builtin -conservative"
+//         "split=builtin -split" "centre=builtin -centre" "movers=builtin -movers" "basic=../basic/MyBot"
+//
+// The leaderboard is saved after every game, so a tournament can be stopped at any time, and a later one (with
+// the same -board) carries on from the ratings it left.
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	local "../../local"
+)
+
+type Entrant struct {
+	Name			string
+	Bot				string					// As for local.NewPlayer()
+}
+
+type Job struct {
+	Round			int
+	Rotation		int
+	Seed			int64
+	Seats			[]*Entrant				// Seat (i.e. player ID) --> entrant
+}
+
+func main() {
+
+	rounds := flag.Int("rounds", 10, "number of rounds (each is one game per seat rotation)")
+	sizes_string := flag.String("sizes", "2,4", "game sizes to cycle through, e.g. 2 or 2,4")
+	parallel := flag.Int("parallel", 2, "games to run at once")
+	board_file := flag.String("board", "leaderboard.json", "leaderboard file, read at the start and saved after each game")
+	seed := flag.Int64("seed", 0, "seed for the first round's map and the pairings (0 for time)")
+	width := flag.Int("width", 0, "map width (0 for random)")
+	turns := flag.Int("turns", local.MAX_TURNS, "maximum turns")
+	timeout := flag.Int("timeout", 2000, "turn timeout in ms for subprocess bots (0 for none)")
+	replay_dir := flag.String("replays", "", "directory to save replays in (default: none saved)")
+	dir := flag.String("dir", "", "working directory for subprocess bots")
+
+	flag.Parse()
+
+	entrants, err := parse_entrants(flag.Args())
+	if err == nil && len(entrants) < 2 {
+		err = fmt.Errorf("need at least 2 entrants")
+	}
+
+	var sizes []int
+	if err == nil {
+		sizes, err = parse_sizes(*sizes_string, len(entrants))
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n", err)
+		fmt.Fprintf(os.Stderr, "Usage: tournament [flags] name=bot name=bot...\n")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	board, err := local.LoadLeaderboard(*board_file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano() % 1000000000
+	}
+
+	jobs := schedule(entrants, sizes, *rounds, *seed)
+
+	fmt.Printf("%d games (%d rounds), first seed %d\n", len(jobs), *rounds, *seed)
+
+	// Workers take jobs in order; results are applied to the leaderboard as they finish...
+
+	job_chan := make(chan *Job)
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+
+	for n := 0; n < *parallel; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range job_chan {
+
+				match := &local.Match{
+					Seed: job.Seed,
+					Width: *width,
+					MaxTurns: *turns,
+					TurnTimeout: time.Duration(*timeout) * time.Millisecond,
+				}
+
+				if *replay_dir != "" {
+					match.ReplayFile = filepath.Join(*replay_dir, fmt.Sprintf("round%03d-%d-seed%d.hlt", job.Round, job.Rotation, job.Seed))
+				}
+
+				var names []string
+				for _, entrant := range job.Seats {
+					player, err := local.NewPlayer(entrant.Bot, *dir)
+					if err != nil {									// Only bad builtin flags; checked in parse_entrants()
+						panic(err)
+					}
+					match.Players = append(match.Players, player)
+					names = append(names, entrant.Name)
+				}
+
+				result := match.Run()
+
+				lock.Lock()
+				record(board, *board_file, job, names, result)
+				lock.Unlock()
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		job_chan <- job
+	}
+	close(job_chan)
+	wg.Wait()
+
+	fmt.Printf("\n%s", board)
+}
+
+func parse_entrants(args []string) ([]*Entrant, error) {
+
+	// "name=bot", or just "bot", in which case the bot is its own name. Names must be unique.
+
+	var ret []*Entrant
+	seen := make(map[string]bool)
+
+	for _, arg := range args {
+
+		entrant := &Entrant{Name: arg, Bot: arg}
+
+		if i := strings.Index(arg, "="); i > 0 && strings.ContainsAny(arg[:i], " \t") == false {
+			entrant.Name, entrant.Bot = arg[:i], arg[i + 1:]
+		}
+
+		if seen[entrant.Name] {
+			return nil, fmt.Errorf("entrant name %q used twice", entrant.Name)
+		}
+		seen[entrant.Name] = true
+
+		if _, err := local.NewPlayer(entrant.Bot, ""); err != nil {
+			return nil, fmt.Errorf("%s: %v", entrant.Name, err)
+		}
+
+		ret = append(ret, entrant)
+	}
+
+	return ret, nil
+}
+
+func parse_sizes(s string, entrants int) ([]int, error) {
+
+	var ret []int
+
+	for _, field := range strings.Split(s, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || (size != 2 && size != 4) {
+			return nil, fmt.Errorf("game sizes must be 2 or 4, not %q", field)
+		}
+		if size > entrants {
+			return nil, fmt.Errorf("%d player games need at least %d entrants", size, size)
+		}
+		ret = append(ret, size)
+	}
+
+	return ret, nil
+}
+
+func schedule(entrants []*Entrant, sizes []int, rounds int, seed int64) []*Job {
+
+	// Each round takes whoever has played least so far (ties broken at random), in a random order of seats,
+	// then adds a game for each rotation of those seats, all on the same map.
+
+	rng := rand.New(rand.NewSource(seed))
+	played := make(map[*Entrant]int)
+
+	var ret []*Job
+
+	for round := 0; round < rounds; round++ {
+
+		size := sizes[round % len(sizes)]
+
+		pool := make([]*Entrant, len(entrants))
+		copy(pool, entrants)
+		rng.Shuffle(len(pool), func(a, b int) {
+			pool[a], pool[b] = pool[b], pool[a]
+		})
+		sort.SliceStable(pool, func(a, b int) bool {
+			return played[pool[a]] < played[pool[b]]
+		})
+
+		group := pool[:size]
+		rng.Shuffle(len(group), func(a, b int) {
+			group[a], group[b] = group[b], group[a]
+		})
+
+		for rotation := 0; rotation < size; rotation++ {
+
+			job := &Job{Round: round, Rotation: rotation, Seed: seed + int64(round)}
+
+			for seat := 0; seat < size; seat++ {
+				job.Seats = append(job.Seats, group[(seat + rotation) % size])
+			}
+
+			ret = append(ret, job)
+		}
+
+		for _, entrant := range group {
+			played[entrant] += size
+		}
+	}
+
+	return ret
+}
+
+func record(board *local.Leaderboard, board_file string, job *Job, names []string, result *local.Result) {
+
+	var ranks []int
+	var placings []string
+
+	for _, player := range result.Players {
+		ranks = append(ranks, player.Rank)
+	}
+
+	board.Update(names, ranks)
+
+	// e.g. "round 3.1 (seed 123, 212 turns): 1 conservative, 2 bot"
+
+	by_rank := make([]*local.PlayerResult, len(result.Players))
+	copy(by_rank, result.Players)
+	sort.Slice(by_rank, func(a, b int) bool {
+		return by_rank[a].Rank < by_rank[b].Rank
+	})
+
+	for _, player := range by_rank {
+		placing := fmt.Sprintf("%d %s", player.Rank, names[player.Pid])
+		if player.Error != nil {
+			placing += fmt.Sprintf(" (%v)", player.Error)
+		}
+		placings = append(placings, placing)
+	}
+
+	fmt.Printf("round %d.%d (seed %d, %d turns): %s\n", job.Round, job.Rotation, job.Seed, result.Turns, strings.Join(placings, ", "))
+
+	err := board.Save(board_file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Couldn't save leaderboard: %v\n", err)
+	}
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// Every expensive planner should get its deadline from the turn's Budget rather than keeping its own
+// clock. The deadline is the engine's limit, minus a hard safety margin that is never handed out,
+// minus a reserve for whatever the bot does after the planners finish. The reserve adapts to how slow
+// recent turns were: it's the worst "tail" over the last few turns, where a turn's tail is the time
+// from the planners finishing (see PlannersDone()) to the output being sent, or its overrun past the
+// deadline if that's longer (e.g. a planner overshot).
+
+const (
+	TURN_TIME_LIMIT = 2000 * time.Millisecond
+	BUDGET_SAFETY_MARGIN = 500 * time.Millisecond
+	BUDGET_MAX_RESERVE = 500 * time.Millisecond
+	BUDGET_HISTORY = 10
+)
+
+type Budget struct {
+	start			time.Time
+	reserve			time.Duration
+	planners_done	time.Time			// Zero until PlannersDone() is called
+	finished		bool
+	overrun			time.Duration		// Only valid once finished
+	tail			time.Duration		// Likewise
+}
+
+func NewBudget(start time.Time, recent_tails []time.Duration) *Budget {
+
+	ret := &Budget{start: start}
+
+	for _, tail := range recent_tails {
+		if tail > ret.reserve {
+			ret.reserve = tail
+		}
+	}
+
+	if ret.reserve > BUDGET_MAX_RESERVE {
+		ret.reserve = BUDGET_MAX_RESERVE
+	}
+
+	return ret
+}
+
+func (self *Budget) Start() time.Time { return self.start }
+func (self *Budget) Reserve() time.Duration { return self.reserve }
+
+func (self *Budget) Deadline() time.Time {
+	return self.start.Add(TURN_TIME_LIMIT - BUDGET_SAFETY_MARGIN - self.reserve)
+}
+
+func (self *Budget) Remaining() time.Duration {
+	return self.Deadline().Sub(time.Now())
+}
+
+func (self *Budget) Expired() bool {
+	return time.Now().After(self.Deadline())
+}
+
+func (self *Budget) Share(fraction float64) time.Time {
+
+	// A deadline for a subsystem that should only use some fraction of the time that's left,
+	// leaving the rest for whoever comes after it.
+
+	remaining := self.Remaining()
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return time.Now().Add(time.Duration(float64(remaining) * fraction))
+}
+
+func (self *Budget) Context() (context.Context, context.CancelFunc) {
+	return context.WithDeadline(context.Background(), self.Deadline())
+}
+
+func (self *Budget) PlannersDone() {
+
+	// Called once nothing more will look at the deadline this turn. Only the first call counts.
+
+	if self.planners_done.IsZero() {
+		self.planners_done = time.Now()
+	}
+}
+
+func (self *Budget) Finish() {
+
+	// Called when the turn's output is sent. Only the first call counts.
+
+	if self.finished {
+		return
+	}
+
+	self.PlannersDone()				// In case nobody called it.
+
+	now := time.Now()
+
+	self.finished = true
+	self.overrun = now.Sub(self.Deadline())
+
+	if self.overrun < 0 {
+		self.overrun = 0
+	}
+
+	self.tail = now.Sub(self.planners_done)
+
+	if self.overrun > self.tail {
+		self.tail = self.overrun
+	}
+}
+
+func (self *Budget) Overrun() time.Duration {
+	return self.overrun
+}
+
+func (self *Budget) Tail() time.Duration {
+	return self.tail
+}
+
+// ---------------------------------------
+
+func (self *Game) Budget() *Budget {
+	return self.budget
+}
+
+func (self *Game) new_budget() {
+
+	// Called at parse time. The previous turn's tail (if it was finished) goes into the history.
+
+	if self.budget != nil && self.budget.finished {
+		self.budget_tails = append(self.budget_tails, self.budget.tail)
+		if len(self.budget_tails) > BUDGET_HISTORY {
+			self.budget_tails = self.budget_tails[1:]
+		}
+	}
+
+	self.budget = NewBudget(self.parse_time, self.budget_tails)
+}
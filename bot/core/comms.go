@@ -3,6 +3,7 @@ package core
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"sort"
@@ -20,8 +21,16 @@ type TokenParser struct {
 }
 
 func NewTokenParser() *TokenParser {
+	return NewReaderTokenParser(os.Stdin)
+}
+
+func NewStringTokenParser(s string) *TokenParser {
+	return NewReaderTokenParser(strings.NewReader(s))
+}
+
+func NewReaderTokenParser(r io.Reader) *TokenParser {
 	ret := new(TokenParser)
-	ret.scanner = bufio.NewScanner(os.Stdin)
+	ret.scanner = bufio.NewScanner(r)
 	ret.scanner.Split(bufio.ScanWords)
 	return ret
 }
@@ -96,7 +105,7 @@ func (self *Game) Parse() {
 
 	// Now reset various things...
 
-	self.orders = make(map[int]string)			// Clear all orders.
+	self.orders = make(map[int]Order)			// Clear all orders.
 	self.messages = make(map[int]int)
 
 	if self.inited {
@@ -130,6 +139,7 @@ func (self *Game) Parse() {
 	// Player parsing.............................................................................
 
 	self.parse_time = time.Now()				// MUST happen AFTER the first token parse. <------------------------------------- important
+	self.new_budget()
 
 	if self.initialPlayers == 0 {
 		self.initialPlayers = player_count		// Only save this at init stage.
@@ -184,6 +194,8 @@ func (self *Game) Parse() {
 
 			self.token_parser.Int()									// Skip deprecated "cooldown"
 
+			ship.History.Push(ShipRecord{self.turn, ship.X, ship.Y, ship.HP, ship.DockedStatus})
+
 			if last_ship == nil {
 				ship.Birth = Max(0, self.turn)						// Turn can be -1 in init stage.
 				ship.SpawnX = ship.X
@@ -198,13 +210,10 @@ func (self *Game) Parse() {
 
 			// Some inferred tactical info that we will set later...
 
-			if ship.Doomed {
-				self.Log("Ship %d survived previous turn despite us predicting its doom.", ship.Id)
+			if ship.DoomChance >= 1 {
+				self.Logger("core").Ship(ship.Id).Warn("Survived previous turn despite us predicting its doom.")
 			}
 
-			ship.Firing = false
-			ship.Doomed = false
-
 			// Add the ship to our maps (if needed)...
 
 			self.shipMap[sid] = ship
@@ -289,51 +298,6 @@ func (self *Game) Parse() {
 		})
 	}
 
-	// Query responses (see info.go)... while these could be done interleaved with the above, they are separated for clarity.
-
-	self.all_ships_cache = nil
-	for _, ship := range self.shipMap {
-		self.all_ships_cache = append(self.all_ships_cache, ship)
-	}
-	sort.Slice(self.all_ships_cache, func(a, b int) bool {
-		return self.all_ships_cache[a].Id < self.all_ships_cache[b].Id
-	})
-
-	self.enemy_ships_cache = nil
-	for _, ship := range self.shipMap {
-		if ship.Owner != self.pid {
-			self.enemy_ships_cache = append(self.enemy_ships_cache, ship)
-		}
-	}
-	sort.Slice(self.enemy_ships_cache, func(a, b int) bool {
-		return self.enemy_ships_cache[a].Id < self.enemy_ships_cache[b].Id
-	})
-
-	self.all_planets_cache = nil
-	for _, planet := range self.planetMap {
-		self.all_planets_cache = append(self.all_planets_cache, planet)
-	}
-	sort.Slice(self.all_planets_cache, func(a, b int) bool {
-		return self.all_planets_cache[a].Id < self.all_planets_cache[b].Id
-	})
-
-	self.all_immobile_cache = nil
-	for _, planet := range self.planetMap {
-		self.all_immobile_cache = append(self.all_immobile_cache, planet)
-		for _, ship := range self.ShipsDockedAt(planet) {
-			self.all_immobile_cache = append(self.all_immobile_cache, ship)
-		}
-	}
-	sort.Slice(self.all_immobile_cache, func(a, b int) bool {
-		if self.all_immobile_cache[a].Type() == PLANET && self.all_immobile_cache[b].Type() == SHIP {
-			return true
-		}
-		if self.all_immobile_cache[a].Type() == SHIP && self.all_immobile_cache[b].Type() == PLANET {
-			return false
-		}
-		return self.all_immobile_cache[a].GetId() < self.all_immobile_cache[b].GetId()
-	})
-
 	// Some meta info...
 
 	self.currentPlayers = players_with_ships
@@ -346,17 +310,13 @@ func (self *Game) Parse() {
 		self.run_of_sames = 0
 	}
 
-	self.UpdateEnemyMaps()
-	self.UpdateFriendMap()
-	self.PredictTimeZero()
-	self.UpdateShipNearestEnemies()
+	self.build_caches()
 }
 
 // ---------------------------------------
 
 func (self *Game) Thrust(ship *Ship, speed, degrees int) {
-	for degrees < 0 { degrees += 360 }; degrees %= 360
-	self.orders[ship.Id] = fmt.Sprintf("t %d %d %d", ship.Id, speed, degrees)
+	self.orders[ship.Id] = Thrust(speed, degrees)
 }
 
 func (self *Game) SetMessage(ship *Ship, message int) {
@@ -367,26 +327,45 @@ func (self *Game) SetMessage(ship *Ship, message int) {
 }
 
 func (self *Game) Dock(ship *Ship, planet Planet) {
-	self.orders[ship.Id] = fmt.Sprintf("d %d %d", ship.Id, planet.Id)
+	self.orders[ship.Id] = Dock(planet.Id)
 }
 
 func (self *Game) Undock(ship *Ship) {
-	self.orders[ship.Id] = fmt.Sprintf("u %d", ship.Id)
+	self.orders[ship.Id] = Undock()
 }
 
 func (self *Game) ClearOrder(ship *Ship) {
 	delete(self.orders, ship.Id)
 }
 
-func (self *Game) CurrentOrder(ship *Ship) string {
+func (self *Game) CurrentOrder(ship *Ship) Order {
 	return self.orders[ship.Id]
 }
 
-func (self *Game) RawOrder(sid int, s string) {
-	self.orders[sid] = s
+func (self *Game) CurrentMessage(ship *Ship) int {		// -1 if none
+	message, ok := self.messages[ship.Id]
+	if ok == false {
+		return -1
+	}
+	return message
+}
+
+func (self *Game) SetOrder(sid int, order Order) {
+	if order.Type == NO_ORDER {
+		delete(self.orders, sid)
+		return
+	}
+	self.orders[sid] = order
+}
+
+func (self *Game) SendName(name string) {			// The reply to the init message.
+	fmt.Fprintf(self.out, "%s\n", name)
 }
 
 func (self *Game) Send(no_messages bool) {
-	fmt.Printf(self.RawOutput(false, no_messages))
-	fmt.Printf("\n")
+	self.budget.PlannersDone()
+	self.ValidateOrders(no_messages)
+	self.flush_trace(no_messages)
+	fmt.Fprintf(self.out, "%s\n", self.RawOutput(false, no_messages))
+	self.budget.Finish()
 }
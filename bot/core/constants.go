@@ -6,6 +6,16 @@ const (
 	MAX_SPEED = 7
 	WEAPON_DAMAGE = 64
 	WEAPON_RANGE = 5.0
+
+	// The following are only really needed for full simulation of the game...
+
+	WEAPON_COOLDOWN = 1
+	EXPLOSION_RADIUS = 10.0
+	MAX_SHIP_HEALTH = 255
+	DOCK_TURNS = 5
+	BASE_PRODUCTIVITY = 6
+	PRODUCTION_PER_SHIP = 72
+	SPAWN_RADIUS = 2
 )
 
 type DockedStatus int
@@ -29,6 +39,16 @@ const (
 	NOTHING
 )
 
+type OrderType int
+
+const (
+	NO_ORDER OrderType = iota
+	HOLD
+	THRUST
+	DOCK
+	UNDOCK
+)
+
 type Edge int
 
 const (
@@ -38,4 +58,4 @@ const (
 	RIGHT
 )
 
-var BackendDevLog = NewLog("backend_dev_log.txt")
+var BackendDevLog = NewLog("", "backend_dev_log.txt", LOG_DEBUG)
@@ -0,0 +1,215 @@
+package core
+
+import (
+	"math"
+	"sort"
+)
+
+// Forecasting production, following the engine's rules: at the start of each turn, after docking progresses,
+// each owned planet gains BASE_PRODUCTIVITY for every fully docked ship; if it then has PRODUCTION_PER_SHIP
+// or more, a ship appears at the planet's spawn point (see SpawnPoint()) and that much is used up. Only one
+// ship per planet per turn. If there's no clear spawn point, production is kept until there is.
+//
+// Since the parser has already pushed docking status forward a turn (see fudge_dock_status()), a ship we see
+// as DOCKED produces on the very next turn, and a DOCKING ship with DockingProgress n starts producing n turns
+// after that. A planet's CurrentProduction is what it had at the end of the turn we're looking at.
+//
+// Where the ship appears is the engine's choice of the clear point nearest the map centre (see SpawnPoint()),
+// where clear means there's nothing within three ship radii of the point. The engine checks this after the turn's
+// movement, but we only have the positions we see now, so the point can be off when ships are passing by.
+// The genetic package's TurnSim uses the same rule.
+//
+// The forecast assumes nothing else changes: no ship docks, undocks or dies, and the spawn points stay as
+// clear (or not) as they are now.
+
+const (
+	SPAWN_CLEAR_SHIPS = SHIP_RADIUS * 4		// A spawn point needs no ship centre this close...
+	SPAWN_CLEAR_PLANETS = SHIP_RADIUS * 3	// ...and no planet surface this close
+)
+
+type SpawnForecast struct {
+	Planet				*Planet
+	Owner				int
+	Turn				int					// The first turn the new ship will be seen on
+	X					float64
+	Y					float64
+}
+
+func (self *Game) ProductionRate(planet *Planet) int {
+
+	// Production per turn, once all the planet's docking ships have finished docking.
+
+	ret := 0
+
+	for _, ship := range self.dockMap[planet.Id] {
+		if ship.DockedStatus == DOCKED || ship.DockedStatus == DOCKING {
+			ret += BASE_PRODUCTIVITY
+		}
+	}
+
+	return ret
+}
+
+func (self *Game) PlayerProductionRate(pid int) int {
+	ret := 0
+	for _, planet := range self.playerplanetMap[pid] {
+		ret += self.ProductionRate(planet)
+	}
+	return ret
+}
+
+func (self *Game) PlanetSpawns(planet *Planet, turns int) []*SpawnForecast {
+
+	// Every spawn at the planet during the next n turns, in order.
+
+	var ret []*SpawnForecast
+
+	if planet.Owned == false {
+		return ret
+	}
+
+	x, y, ok := self.SpawnPoint(planet)
+
+	if ok == false {
+		return ret
+	}
+
+	production := planet.CurrentProduction
+
+	for k := 1; k <= turns; k++ {
+
+		for _, ship := range self.dockMap[planet.Id] {
+			if ship.DockedStatus == DOCKED || (ship.DockedStatus == DOCKING && k > ship.DockingProgress) {
+				production += BASE_PRODUCTIVITY
+			}
+		}
+
+		if production >= PRODUCTION_PER_SHIP {
+			production -= PRODUCTION_PER_SHIP
+			ret = append(ret, &SpawnForecast{
+				Planet: planet,
+				Owner: planet.Owner,
+				Turn: self.turn + k,
+				X: x,
+				Y: y,
+			})
+		}
+	}
+
+	return ret
+}
+
+func (self *Game) NextSpawn(planet *Planet, turns int) (*SpawnForecast, bool) {
+
+	// The planet's next spawn, if it happens within n turns.
+
+	spawns := self.PlanetSpawns(planet, turns)
+
+	if len(spawns) == 0 {
+		return nil, false
+	}
+	return spawns[0], true
+}
+
+func (self *Game) ForecastSpawns(turns int) []*SpawnForecast {
+
+	// Every spawn in the game during the next n turns, sorted by turn, then planet ID.
+
+	var ret []*SpawnForecast
+
+	for _, planet := range self.AllPlanets() {
+		ret = append(ret, self.PlanetSpawns(planet, turns)...)
+	}
+
+	sort.SliceStable(ret, func(a, b int) bool {
+		if ret[a].Turn != ret[b].Turn {
+			return ret[a].Turn < ret[b].Turn
+		}
+		return ret[a].Planet.Id < ret[b].Planet.Id
+	})
+
+	return ret
+}
+
+func (self *Game) ForecastShipCounts(turns int) map[int][]int {
+
+	// Player ID --> ship count on each of the next n turns, with index 0 being now.
+	// Every player that has ships, or owns a planet, is present.
+
+	ret := make(map[int][]int)
+
+	for pid := 0; pid < self.initialPlayers; pid++ {
+
+		if len(self.playershipMap[pid]) == 0 && len(self.playerplanetMap[pid]) == 0 {
+			continue
+		}
+
+		ret[pid] = make([]int, turns + 1)
+		for k := 0; k <= turns; k++ {
+			ret[pid][k] = len(self.playershipMap[pid])
+		}
+	}
+
+	for _, spawn := range self.ForecastSpawns(turns) {
+		for k := spawn.Turn - self.turn; k <= turns; k++ {
+			ret[spawn.Owner][k]++
+		}
+	}
+
+	return ret
+}
+
+func (self *Game) SpawnPoint(planet *Planet) (float64, float64, bool) {
+
+	// Where the planet's next ship would appear if it spawned now; false if there's no clear point.
+
+	return SpawnPoint(self.width, self.height, planet.X, planet.Y, planet.Radius, func(x, y float64) bool {
+		if len(self.ShipsWithin(x, y, SPAWN_CLEAR_SHIPS)) > 0 {
+			return false
+		}
+		for _, other := range self.all_planets_cache {
+			if Dist(x, y, other.X, other.Y) <= other.Radius + SPAWN_CLEAR_PLANETS {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func SpawnPoint(width, height int, planet_x, planet_y, planet_radius float64, clear func(x, y float64) bool) (float64, float64, bool) {
+
+	// The engine tries a little grid of points around the planet's edge, choosing the
+	// clear one that's closest to the centre of the map. What counts as clear is up to the caller.
+
+	centre_x, centre_y := float64(width) / 2, float64(height) / 2
+
+	best_x, best_y := 0.0, 0.0
+	best_dist := 999999.9
+	found := false
+
+	for dx := -SPAWN_RADIUS; dx <= SPAWN_RADIUS; dx++ {
+
+		for dy := -SPAWN_RADIUS; dy <= SPAWN_RADIUS; dy++ {
+
+			// Added up in the engine's order. Points are often equally far from the centre, so the last bit matters...
+
+			offset_angle := math.Atan2(float64(dy), float64(dx))
+			x := planet_x + (float64(dx) + planet_radius * math.Cos(offset_angle))
+			y := planet_y + (float64(dy) + planet_radius * math.Sin(offset_angle))
+
+			if x < 0 || x >= float64(width) || y < 0 || y >= float64(height) {
+				continue
+			}
+
+			d := Dist(x, y, centre_x, centre_y)
+
+			if d < best_dist && clear(x, y) {
+				best_x, best_y = x, y
+				best_dist = d
+				found = true
+			}
+		}
+	}
+
+	return best_x, best_y, found
+}
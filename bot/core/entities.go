@@ -2,6 +2,7 @@ package core
 
 import (
 	"fmt"
+	"math"
 )
 
 // ------------------------------------------------------
@@ -83,13 +84,16 @@ type Ship struct {
 	SpawnX				float64
 	SpawnY				float64
 
-	Firing				bool		// Whether the ship will fire at Time 0 this turn (unless it docks)
-	Doomed				bool		// Whether the ship will die at Time 0 this turn (unless nearby enemy ships dock)
+	FireChance			float64		// Chance the ship fires at Time 0 this turn      (see risk.go)
+	DoomChance			float64		// Chance the ship dies at Time 0 this turn
+	DeathChance			float64		// Chance the ship dies at any time this turn
+	ExpectedDamage		float64		// Mean damage the ship takes this turn
 
 	Dx					float64
 	Dy					float64
 	LastSpeed			int
 	LastAngle			int
+	History				ShipHistory	// The last few turns, including this one
 
 	ClosestEnemy		*Ship
 }
@@ -111,38 +115,30 @@ func (s *Ship) ShotsToKill() int {
 
 func (s *Ship) VagueDirection() Edge {
 
-	// Was the ship's last move mostly up, down, left, or right?
+	// Were the ship's recent moves mostly up, down, left, or right?
+	// Uses the net movement over the last few turns, so one sidestep doesn't count for much.
 	// Note that stationary ships return RIGHT.
 
-	if s.LastSpeed == 0 { return RIGHT }
-	if s.LastAngle > 360 { panic("VagueDirection(): ship had angle > 360") }
+	dx, dy := s.History.Moves(INTENT_TURNS)
 
-	if s.LastAngle >= 315 || s.LastAngle < 45 { return RIGHT }
-	if s.LastAngle >= 135 && s.LastAngle < 225 { return LEFT }
-	if s.LastAngle < 180 { return BOTTOM }
-	return TOP
-}
-
-func (s *Ship) find_closest_enemy(game *Game) *Ship {
-
-	var ret *Ship
+	var sum_x, sum_y float64
+	for n := range dx {
+		sum_x += dx[n]
+		sum_y += dy[n]
+	}
 
-	closest_dist := 9999.9
+	if Round(math.Sqrt(sum_x * sum_x + sum_y * sum_y)) == 0 { return RIGHT }
 
-	for _, other := range game.AllShips() {
+	angle := Angle(0, 0, sum_x, sum_y)
 
-		if other.Owner == s.Owner {
-			continue
-		}
-
-		d := s.Dist(other)
-		if d < closest_dist {
-			ret = other
-			closest_dist = d
-		}
-	}
+	if angle >= 315 || angle < 45 { return RIGHT }
+	if angle >= 135 && angle < 225 { return LEFT }
+	if angle < 180 { return BOTTOM }
+	return TOP
+}
 
-	return ret
+func (s *Ship) find_closest_enemy(game *Game) *Ship {
+	return game.NearestEnemy(s)
 }
 
 func (s *Ship) fudge_dock_status() {
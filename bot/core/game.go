@@ -2,6 +2,8 @@ package core
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"sort"
 	"strings"
 	"time"
@@ -46,15 +48,19 @@ type Game struct {
 	cumulativeShips				map[int]int			// Player ID --> Count
 	lastownerMap				map[int]int			// Planet ID --> Last owner (check OK for never owned)
 
-	orders						map[int]string
+	orders						map[int]Order
 	messages					map[int]int			// For the Chlorine viewer
 
 	logfile						*Logfile
+	tracer						*Tracer
 	token_parser				*TokenParser
+	out							io.Writer
 	raw							string
 	run_of_sames				int
 
 	parse_time					time.Time
+	budget						*Budget
+	budget_tails				[]time.Duration
 
 	// These slices are kept as answers to common queries...
 
@@ -62,6 +68,8 @@ type Game struct {
 	enemy_ships_cache			[]*Ship
 	all_planets_cache			[]*Planet
 	all_immobile_cache			[]Entity			// Planets and docked ships
+	ship_index					*SpatialIndex
+	intents						map[int]*Intent		// Ship ID --> Intent, filled in as asked-for
 
 	// Some more stuff maybe used by the AI...
 
@@ -73,9 +81,26 @@ type Game struct {
 }
 
 func NewGame() *Game {
+	return NewGameFromTransport(StdioTransport())
+}
+
+func NewGameFromTransport(transport Transport) *Game {
+	return new_game(NewReaderTokenParser(transport), transport, -1)
+}
+
+func NewGameFromString(s string, turn int) *Game {
+
+	// The string should be exactly what the engine would send: our pid, the map size, then the world.
+	// This lets simulators hand back a real Game. Note that no further Parse() is possible.
+
+	return new_game(NewStringTokenParser(s), ioutil.Discard, turn)
+}
+
+func new_game(token_parser *TokenParser, out io.Writer, turn int) *Game {
 	game := new(Game)
-	game.turn = -1
-	game.token_parser = NewTokenParser()
+	game.turn = turn
+	game.token_parser = token_parser
+	game.out = out
 	game.pid = game.token_parser.Int()
 	game.width = game.token_parser.Int()
 	game.height = game.token_parser.Int()
@@ -106,12 +131,9 @@ func (self *Game) UpdateEnemyMaps() {
 	self.enemies_near_planet = make(map[int][]*Ship)
 	self.mobile_enemies_near_planet = make(map[int][]*Ship)
 
-	all_ships := self.AllShips()
-	all_planets := self.AllPlanets()
-
-	for _, ship := range all_ships {
-		if ship.Owner != self.Pid() {
-			for _, planet := range all_planets {
+	for _, planet := range self.AllPlanets() {
+		for _, ship := range self.ShipsWithin(planet.X, planet.Y, planet.Radius + self.threat_range) {
+			if ship.Owner != self.Pid() {
 				if ship.ApproachDist(planet) < self.threat_range {
 
 					// enemies_near_planet includes all mobile enemies, plus enemies docked at the planet...
@@ -135,12 +157,9 @@ func (self *Game) UpdateFriendMap() {
 
 	self.friends_near_planet = make(map[int][]*Ship)
 
-	my_ships := self.MyShips()
-	all_planets := self.AllPlanets()
-
-	for _, ship := range my_ships {
-		for _, planet := range all_planets {
-			if ship.CanMove() {
+	for _, planet := range self.AllPlanets() {
+		for _, ship := range self.ShipsWithin(planet.X, planet.Y, planet.Radius + self.friend_range) {
+			if ship.Owner == self.pid && ship.CanMove() {
 				if ship.ApproachDist(planet) < self.friend_range {
 					self.friends_near_planet[planet.Id] = append(self.friends_near_planet[planet.Id], ship)
 				}
@@ -169,31 +188,20 @@ func (self *Game) RawWorld() string {
 
 func (self *Game) RawOutput(sorted, no_messages bool) string {
 
-	var commands []string
-
-	for sid, s := range self.orders {
-
-		if s != "" {
+	// This is the only place orders become strings.
 
-			message, ok := self.messages[sid]
-
-			if no_messages == false && s[0] == 't' && ok {
-
-				speed, degrees := CourseFromString(s)
-
-				// We put some extra info into the angle, which we can see in the Chlorine replayer...
-
-				if message >= 0 && message <= 180 {
-					degrees += (int(message) + 1) * 360
-				}
+	var commands []string
 
-				commands = append(commands, fmt.Sprintf("t %d %d %d", sid, speed, degrees))
+	for sid, order := range self.orders {
 
-			} else {
+		message, ok := self.messages[sid]
 
-				commands = append(commands, s)
+		if no_messages || ok == false {
+			message = -1
+		}
 
-			}
+		if command := order.Command(sid, message); command != "" {
+			commands = append(commands, command)
 		}
 	}
 
@@ -206,70 +214,65 @@ func (self *Game) RawOutput(sorted, no_messages bool) string {
 	return strings.Join(commands, " ")
 }
 
-func (self *Game) PredictTimeZero() {
-
-	// Weapons fire at Time 0 is almost entirely predictable (unless ships involved dock, which is generally unlikely).
-	// Therefore, we set 2 flags on every ship indicating about those events.
-
-	var all_shots = make(map[int][]int)		// Ship ID --> target IDs
-	var incoming = make(map[int]int)		// Ship ID --> damage coming in
-
-	all_ships := self.AllShips()
-
-	for i := 0; i < len(all_ships); i++ {
-
-		ship1 := all_ships[i]
-
-		for k := i + 1; k < len(all_ships); k++ {
-
-			ship2 := all_ships[k]
-
-			if ship1.Owner == ship2.Owner {
-				continue
-			}
-
-			if ship1.Dist(ship2) > WEAPON_RANGE + SHIP_RADIUS * 2 {
-				continue
-			}
-
-			// They will fire on each other, unless they are docked or dock now...
-
-			if ship1.DockedStatus == UNDOCKED {
-				all_shots[ship1.Id] = append(all_shots[ship1.Id], ship2.Id)
-			}
-			if ship2.DockedStatus == UNDOCKED {
-				all_shots[ship2.Id] = append(all_shots[ship2.Id], ship1.Id)
-			}
-		}
+func (self *Game) UpdateShipNearestEnemies() {
+	for _, ship := range self.all_ships_cache {
+		ship.ClosestEnemy = ship.find_closest_enemy(self)
 	}
+}
 
-	for _, ship := range all_ships {
+func (self *Game) build_caches() {
 
-		shots := all_shots[ship.Id]
+	// Query responses (see game_info.go), and the AI maps, all rebuilt from the shipMap, planetMap and dockMap.
+	// Parse() calls this, as do Snapshot() and Apply(), so a copy's caches can never point into another Game.
 
-		if len(shots) == 0 {
-			continue
-		} else {
-			ship.Firing = true
-		}
+	self.all_ships_cache = nil
+	for _, ship := range self.shipMap {
+		self.all_ships_cache = append(self.all_ships_cache, ship)
+	}
+	sort.Slice(self.all_ships_cache, func(a, b int) bool {
+		return self.all_ships_cache[a].Id < self.all_ships_cache[b].Id
+	})
 
-		damage := WEAPON_DAMAGE / len(shots)		// Right? A straight up integer truncation?
+	self.ship_index = NewSpatialIndex(self.width, self.height, self.all_ships_cache)
+	self.intents = nil
 
-		for _, target_id := range shots {
-			incoming[target_id] += damage
+	self.enemy_ships_cache = nil
+	for _, ship := range self.shipMap {
+		if ship.Owner != self.pid {
+			self.enemy_ships_cache = append(self.enemy_ships_cache, ship)
 		}
 	}
+	sort.Slice(self.enemy_ships_cache, func(a, b int) bool {
+		return self.enemy_ships_cache[a].Id < self.enemy_ships_cache[b].Id
+	})
 
-	for _, ship := range all_ships {
-
-		if incoming[ship.Id] >= ship.HP {
-			ship.Doomed = true
+	self.all_planets_cache = nil
+	for _, planet := range self.planetMap {
+		self.all_planets_cache = append(self.all_planets_cache, planet)
+	}
+	sort.Slice(self.all_planets_cache, func(a, b int) bool {
+		return self.all_planets_cache[a].Id < self.all_planets_cache[b].Id
+	})
+
+	self.all_immobile_cache = nil
+	for _, planet := range self.planetMap {
+		self.all_immobile_cache = append(self.all_immobile_cache, planet)
+		for _, ship := range self.ShipsDockedAt(planet) {
+			self.all_immobile_cache = append(self.all_immobile_cache, ship)
 		}
 	}
-}
+	sort.Slice(self.all_immobile_cache, func(a, b int) bool {
+		if self.all_immobile_cache[a].Type() == PLANET && self.all_immobile_cache[b].Type() == SHIP {
+			return true
+		}
+		if self.all_immobile_cache[a].Type() == SHIP && self.all_immobile_cache[b].Type() == PLANET {
+			return false
+		}
+		return self.all_immobile_cache[a].GetId() < self.all_immobile_cache[b].GetId()
+	})
 
-func (self *Game) UpdateShipNearestEnemies() {
-	for _, ship := range self.all_ships_cache {
-		ship.ClosestEnemy = ship.find_closest_enemy(self)
-	}
+	self.UpdateEnemyMaps()
+	self.UpdateFriendMap()
+	self.PredictRisk()
+	self.UpdateShipNearestEnemies()
 }
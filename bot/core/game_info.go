@@ -125,6 +125,26 @@ func (self *Game) ShipsDockedAt(planet *Planet) []*Ship {
 	return ret
 }
 
+func (self *Game) ShipsWithin(x, y, r float64) []*Ship {		// Sorted by ID
+	return self.ship_index.ShipsWithin(x, y, r)
+}
+
+func (self *Game) NearestShips(x, y float64, k int, accept func(ship *Ship) bool) []*Ship {		// Sorted by distance
+	return self.ship_index.NearestShips(x, y, k, accept)
+}
+
+func (self *Game) NearestEnemy(ship *Ship) *Ship {
+
+	ret := self.NearestShips(ship.X, ship.Y, 1, func(other *Ship) bool {
+		return other.Owner != ship.Owner
+	})
+
+	if len(ret) == 0 {
+		return nil
+	}
+	return ret[0]
+}
+
 func (self *Game) ClosestPlanet(e Entity) *Planet {
 
 	var best_dist float64 = 9999999
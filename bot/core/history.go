@@ -0,0 +1,63 @@
+package core
+
+// Every ship remembers where it was, and what it was doing, on each of its last few turns. This is a value
+// type held directly in the Ship, so copies of a Ship (as Parse() and Snapshot() make) get their own history.
+
+const (
+	SHIP_HISTORY_LENGTH = 10
+)
+
+type ShipRecord struct {
+	Turn				int
+	X					float64
+	Y					float64
+	HP					int
+	DockedStatus		DockedStatus
+}
+
+type ShipHistory struct {
+	records				[SHIP_HISTORY_LENGTH]ShipRecord
+	next				int					// Where the next record goes
+	count				int
+}
+
+func (self *ShipHistory) Push(record ShipRecord) {
+	self.records[self.next] = record
+	self.next = (self.next + 1) % SHIP_HISTORY_LENGTH
+	if self.count < SHIP_HISTORY_LENGTH {
+		self.count++
+	}
+}
+
+func (self *ShipHistory) Len() int {
+	return self.count
+}
+
+func (self *ShipHistory) Get(n int) ShipRecord {
+
+	// Get(0) is the most recent record (i.e. this turn, once the parser has run), Get(1) the one before...
+
+	if n < 0 || n >= self.count {
+		panic("ShipHistory.Get(): index out of range")
+	}
+	return self.records[(self.next - 1 - n + SHIP_HISTORY_LENGTH * 2) % SHIP_HISTORY_LENGTH]
+}
+
+func (self *ShipHistory) Moves(max int) (dx, dy []float64) {
+
+	// The ship's most recent moves, newest first, for as long as it was undocked and seen every turn.
+
+	for n := 0; n < max && n + 1 < self.count; n++ {
+
+		now, before := self.Get(n), self.Get(n + 1)
+
+		if now.Turn != before.Turn + 1 || now.DockedStatus != UNDOCKED || before.DockedStatus != UNDOCKED {
+			break
+		}
+
+		dx = append(dx, now.X - before.X)
+		dy = append(dy, now.Y - before.Y)
+	}
+
+	return dx, dy
+}
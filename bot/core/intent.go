@@ -0,0 +1,200 @@
+package core
+
+import (
+	"fmt"
+	"math"
+)
+
+// Guessing where a ship is going, from its last few moves. The candidates are every planet, and every docked
+// ship belonging to someone else. Each gets a score for how consistently the ship has been heading at it:
+// the mean (recency weighted) cosine of the angle between each move and the direction to the candidate from
+// where that move started, with turns spent stationary (or moving away) counting as 0. Anything within the
+// angular size of the region the ship needs to reach (docking range of a planet, weapon range of a ship)
+// counts as dead ahead.
+//
+// If no candidate scores well enough, the ship is just going somewhere: a point further along its average
+// heading, scored by how straight it has been flying.
+
+const (
+	INTENT_TURNS = 5					// How many recent moves to consider
+	INTENT_DECAY = 0.8					// Weight of each move relative to the one after it
+	INTENT_MIN_SCORE = 0.9				// Below this, a candidate isn't the destination
+	INTENT_TIE_MARGIN = 0.02			// Candidates scoring this close to the best count as equal; the nearest wins
+	INTENT_MIN_MOVES = 2				// With fewer moves than this, the ship is only going towards a point
+)
+
+type IntentKind int
+
+const (
+	INTENT_NONE IntentKind = iota		// Not moving (or not enough history to say)
+	INTENT_PLANET
+	INTENT_DOCKED_SHIP
+	INTENT_POINT
+)
+
+type Intent struct {
+	Kind				IntentKind
+	Target				Entity				// *Planet, *Ship, or *Point
+	Score				float64
+	Eta					float64				// Turns to arrive, at the ship's recent average speed
+}
+
+func (self *Intent) String() string {
+	return fmt.Sprintf("Intent: %v (score %.2f, eta %.1f)", self.Target, self.Score, self.Eta)
+}
+
+func (self *Game) Intent(ship *Ship) *Intent {
+
+	// Cached until the world changes. Never nil.
+
+	if self.intents == nil {
+		self.intents = make(map[int]*Intent)
+	}
+
+	ret, ok := self.intents[ship.Id]
+	if ok == false {
+		ret = self.infer_intent(ship)
+		self.intents[ship.Id] = ret
+	}
+
+	return ret
+}
+
+func (self *Game) infer_intent(ship *Ship) *Intent {
+
+	ret := &Intent{Kind: INTENT_NONE, Target: Nothing}
+
+	if ship.DockedStatus != UNDOCKED {
+		return ret
+	}
+
+	dx, dy := ship.History.Moves(INTENT_TURNS)
+
+	var total_weight, distance, sum_x, sum_y float64
+
+	weight := 1.0
+
+	for n := range dx {
+		total_weight += weight
+		distance += weight * math.Sqrt(dx[n] * dx[n] + dy[n] * dy[n])
+		sum_x += weight * dx[n]
+		sum_y += weight * dy[n]
+		weight *= INTENT_DECAY
+	}
+
+	if distance == 0 {
+		return ret
+	}
+
+	speed := distance / total_weight
+
+	// Score the candidates...
+
+	var candidates []Entity
+
+	if len(dx) >= INTENT_MIN_MOVES {
+
+		for _, planet := range self.AllPlanets() {
+			candidates = append(candidates, planet)
+		}
+
+		for _, other := range self.AllShips() {
+			if other.Owner != ship.Owner && other.DockedStatus != UNDOCKED {
+				candidates = append(candidates, other)
+			}
+		}
+	}
+
+	var best Entity
+	var best_score float64 = -1
+
+	for _, candidate := range candidates {
+
+		if ship.Dist(candidate) >= Dist(ship.X - dx[0], ship.Y - dy[0], candidate.GetX(), candidate.GetY()) {
+			continue												// Going away from it (maybe having passed it)
+		}
+
+		score := intent_score(ship, dx, dy, candidate)
+
+		if best == nil || score > best_score + INTENT_TIE_MARGIN || (score > best_score - INTENT_TIE_MARGIN && ship.Dist(candidate) < ship.Dist(best)) {
+			best = candidate
+			best_score = score
+		}
+	}
+
+	if best != nil && best_score >= INTENT_MIN_SCORE {
+		ret.Target = best
+		ret.Score = best_score
+		ret.Eta = MaxFloat(0, ship.ApproachDist(best)) / speed
+		if best.Type() == PLANET {
+			ret.Kind = INTENT_PLANET
+		} else {
+			ret.Kind = INTENT_DOCKED_SHIP
+		}
+		return ret
+	}
+
+	// Nothing in particular; so a point along the average heading...
+
+	heading := math.Atan2(sum_y, sum_x)
+	x, y := ship.X + math.Cos(heading) * speed * INTENT_TURNS, ship.Y + math.Sin(heading) * speed * INTENT_TURNS
+
+	ret.Kind = INTENT_POINT
+	ret.Target = &Point{x, y}
+	ret.Score = math.Sqrt(sum_x * sum_x + sum_y * sum_y) / distance			// 1 for a straight line
+	ret.Eta = INTENT_TURNS
+
+	return ret
+}
+
+func intent_score(ship *Ship, dx, dy []float64, candidate Entity) float64 {
+
+	var total, total_weight float64
+
+	weight := 1.0
+	x, y := ship.X, ship.Y
+
+	for n := range dx {
+
+		// The move ended at (x, y) and started at (start_x, start_y)...
+
+		start_x, start_y := x - dx[n], y - dy[n]
+
+		total_weight += weight
+
+		d := Dist(start_x, start_y, candidate.GetX(), candidate.GetY())
+
+		if (dx[n] != 0 || dy[n] != 0) && Dist(x, y, candidate.GetX(), candidate.GetY()) < d {		// Moves away count as 0
+
+			off := math.Abs(math.Atan2(dy[n], dx[n]) - math.Atan2(candidate.GetY() - start_y, candidate.GetX() - start_x))
+			if off > math.Pi {
+				off = 2 * math.Pi - off
+			}
+
+			radius := intent_radius(candidate)
+
+			if d > radius {
+				off = MaxFloat(0, off - math.Asin(radius / d))
+			} else {
+				off = 0
+			}
+
+			total += weight * math.Cos(off)
+		}
+
+		x, y = start_x, start_y
+		weight *= INTENT_DECAY
+	}
+
+	return total / total_weight
+}
+
+func intent_radius(candidate Entity) float64 {
+
+	// How close a ship needs to get to the candidate to do whatever it's there for.
+
+	if candidate.Type() == PLANET {
+		return candidate.GetRadius() + DOCKING_RADIUS
+	}
+	return WEAPON_RANGE
+}
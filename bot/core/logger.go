@@ -1,79 +1,300 @@
 package core
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 )
 
+// The log is a series of records, each with a level, the turn, our player ID, the subsystem it came from, maybe a
+// ship ID, a message, and any number of key/value fields. Records are written as JSON lines (one object per line)
+// so that logs, e.g. from all 4 bots in a local game, can be filtered and joined by machine; and optionally also
+// as text, for reading.
+//
+// Code logs through a Logger, from Game.Logger(subsystem), which can be narrowed down to a ship, given fields,
+// or told to only log something once per game:
+//
+//     game.Logger("pilot").Ship(sid).With("inhibition", x).Debug("Fleeing from %v", enemy)
+//
+// Field values can be anything; see json_value() for how they're written.
+//
+// Records below the Logfile's level are dropped before anything is formatted, so Debug() is cheap when off.
+
+type LogLevel int
+
+const (
+	LOG_DEBUG LogLevel = iota
+	LOG_INFO
+	LOG_WARN
+	LOG_ERROR
+)
+
+func (self LogLevel) String() string {
+	switch self {
+		case LOG_DEBUG: return "debug"
+		case LOG_INFO: return "info"
+		case LOG_WARN: return "warn"
+		case LOG_ERROR: return "error"
+	}
+	return "unknown"
+}
+
+func ParseLogLevel(s string) (LogLevel, error) {
+	for level := LOG_DEBUG; level <= LOG_ERROR; level++ {
+		if strings.ToLower(s) == level.String() {
+			return level, nil
+		}
+	}
+	return LOG_INFO, fmt.Errorf("unknown log level %q", s)
+}
+
+// ---------------------------------------------------------------
+
+type LogRecord struct {
+	Level			LogLevel
+	Turn			int
+	Pid				int
+	Subsystem		string
+	Ship			int					// -1 if not about any one ship
+	Message			string
+	Fields			[]LogField			// In the order given
+}
+
+type LogField struct {
+	Key				string
+	Value			interface{}
+}
+
+func (self *LogRecord) JSON() []byte {
+
+	// Built by hand so the keys come out in a fixed order, which makes the raw file easier on the eye.
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, `{"level":%q,"turn":%d,"pid":%d,"sys":%s`, self.Level.String(), self.Turn, self.Pid, json_value(self.Subsystem))
+
+	if self.Ship >= 0 {
+		fmt.Fprintf(&b, `,"ship":%d`, self.Ship)
+	}
+
+	fmt.Fprintf(&b, `,"msg":%s`, json_value(self.Message))
+
+	if len(self.Fields) > 0 {
+		b.WriteString(`,"fields":{`)
+		for n, field := range self.Fields {
+			if n > 0 {
+				b.WriteString(",")
+			}
+			fmt.Fprintf(&b, `%s:%s`, json_value(field.Key), json_value(field.Value))
+		}
+		b.WriteString("}")
+	}
+
+	b.WriteString("}")
+	return b.Bytes()
+}
+
+func (self *LogRecord) Text() string {
+
+	s := fmt.Sprintf("t %3d: ", self.Turn)
+
+	if self.Level != LOG_INFO {
+		s += strings.ToUpper(self.Level.String()) + " "
+	}
+	if self.Subsystem != "" {
+		s += "[" + self.Subsystem + "] "
+	}
+	if self.Ship >= 0 {
+		s += fmt.Sprintf("ship %d: ", self.Ship)
+	}
+
+	s += self.Message
+
+	if len(self.Fields) > 0 {
+		var parts []string
+		for _, field := range self.Fields {
+			parts = append(parts, fmt.Sprintf("%s=%v", field.Key, field.Value))
+		}
+		s += "  (" + strings.Join(parts, ", ") + ")"
+	}
+
+	return s
+}
+
+func json_value(v interface{}) string {
+
+	// Anything with a String() method goes in as that string (so Ships, Planets, Orders, etc come out as they do
+	// in the text log, and cyclic structures are never walked); and so does anything JSON can't handle (NaN...)
+
+	if raw, ok := v.(json.RawMessage); ok {			// Already JSON (see TraceStep.With)
+		return string(raw)
+	}
+
+	if stringer, ok := v.(fmt.Stringer); ok {
+		v = fmt.Sprintf("%v", stringer)			// fmt copes with nil pointers
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(fmt.Sprintf("%v", v))
+	}
+	return string(b)
+}
+
+// ---------------------------------------------------------------
+
 type Logfile struct {
-	outfile			*os.File
-	outfilename		string
+	json_filename	string				// Either filename can be "" for no such output
+	text_filename	string
+	json_file		*os.File
+	text_file		*os.File
+	level			LogLevel
 	logged_once		map[string]bool
 }
 
-func NewLog(outfilename string) *Logfile {
+func NewLog(json_filename, text_filename string, level LogLevel) *Logfile {
 	return &Logfile{
-		nil,
-		outfilename,
-		make(map[string]bool),
+		json_filename: json_filename,
+		text_filename: text_filename,
+		level: level,
+		logged_once: make(map[string]bool),
 	}
 }
 
-func (self *Logfile) Log(format_string string, args ...interface{}) {
+func (self *Logfile) Enabled(level LogLevel) bool {
+	return self != nil && level >= self.level
+}
+
+func (self *Logfile) Write(record *LogRecord) {
 
-	if self == nil {
+	if self.Enabled(record.Level) == false {
 		return
 	}
 
-	if self.outfile == nil {
-
-		var err error
-
-		if _, tmp_err := os.Stat(self.outfilename); tmp_err == nil {
-			// File exists
-			self.outfile, err = os.OpenFile(self.outfilename, os.O_APPEND|os.O_WRONLY, 0666)
-		} else {
-			// File needs creating
-			self.outfile, err = os.Create(self.outfilename)
+	if self.json_filename != "" {
+		if self.json_file == nil {
+			self.json_file = open_log(self.json_filename)
 		}
-
-		if err != nil {
-			return
+		if self.json_file != nil {
+			self.json_file.Write(append(record.JSON(), '\n'))
 		}
 	}
 
-	fmt.Fprintf(self.outfile, format_string, args...)
-	fmt.Fprintf(self.outfile, "\r\n")                    // Because I use Windows...
+	if self.text_filename != "" {
+		if self.text_file == nil {
+			self.text_file = open_log(self.text_filename)
+		}
+		if self.text_file != nil {
+			fmt.Fprintf(self.text_file, "%s\r\n", record.Text())		// Because I use Windows...
+		}
+	}
 }
 
-func (self *Logfile) LogOnce(format_string string, args ...interface{}) bool {
-	if self.logged_once[format_string] == false {
-		self.logged_once[format_string] = true         // Note that it's format_string that is checked / saved
-		self.Log(format_string, args...)
-		return true
+func open_log(filename string) *os.File {
+
+	var outfile *os.File
+	var err error
+
+	if _, tmp_err := os.Stat(filename); tmp_err == nil {
+		// File exists
+		outfile, err = os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0666)
+	} else {
+		// File needs creating
+		outfile, err = os.Create(filename)
 	}
-	return false
+
+	if err != nil {
+		return nil
+	}
+	return outfile
 }
 
 // ---------------------------------------------------------------
 
-func (self *Game) StartLog(logfilename string) {
-	self.logfile = NewLog(logfilename)
+type Logger struct {
+	game			*Game
+	subsystem		string
+	ship			int
+	once			string
+	fields			[]LogField
+}
+
+func (self *Game) Logger(subsystem string) *Logger {
+	return &Logger{
+		game: self,
+		subsystem: subsystem,
+		ship: -1,
+	}
+}
+
+func (self *Logger) Ship(sid int) *Logger {
+	ret := *self
+	ret.ship = sid
+	return &ret
+}
+
+func (self *Logger) With(key string, value interface{}) *Logger {
+	ret := *self
+	ret.fields = append(self.fields[:len(self.fields):len(self.fields)], LogField{key, value})		// Never share the array
+	return &ret
+}
+
+func (self *Logger) Once(key string) *Logger {
+
+	// Only the first record logged under the key (per subsystem) is written; the rest of the game, it's dropped.
+
+	ret := *self
+	ret.once = key
+	return &ret
 }
 
-func (self *Game) Log(format_string string, args ...interface{}) {
-	format_string = fmt.Sprintf("t %3d: ", self.Turn()) + format_string
-	self.logfile.Log(format_string, args...)
+func (self *Logger) Enabled(level LogLevel) bool {
+
+	// For callers who'd rather not build expensive fields that are going to be thrown away.
+
+	return self.game.logfile.Enabled(level)
 }
 
-func (self *Game) LogOnce(format_string string, args ...interface{}) bool {
-	format_string = "t %3d: " + format_string
-	var newargs []interface{}
-	newargs = append(newargs, self.Turn())
-	newargs = append(newargs, args...)
-	return self.logfile.LogOnce(format_string, newargs...)
+func (self *Logger) Debug(format_string string, args ...interface{}) { self.log(LOG_DEBUG, format_string, args...) }
+func (self *Logger) Info(format_string string, args ...interface{}) { self.log(LOG_INFO, format_string, args...) }
+func (self *Logger) Warn(format_string string, args ...interface{}) { self.log(LOG_WARN, format_string, args...) }
+func (self *Logger) Error(format_string string, args ...interface{}) { self.log(LOG_ERROR, format_string, args...) }
+
+func (self *Logger) log(level LogLevel, format_string string, args ...interface{}) {
+
+	logfile := self.game.logfile
+
+	if logfile.Enabled(level) == false {
+		return
+	}
+
+	if self.once != "" {
+		key := self.subsystem + "/" + self.once
+		if logfile.logged_once[key] {
+			return
+		}
+		logfile.logged_once[key] = true
+	}
+
+	logfile.Write(&LogRecord{
+		Level: level,
+		Turn: self.game.turn,
+		Pid: self.game.pid,
+		Subsystem: self.subsystem,
+		Ship: self.ship,
+		Message: fmt.Sprintf(format_string, args...),
+		Fields: self.fields,
+	})
+}
+
+// ---------------------------------------------------------------
+
+func (self *Game) StartLog(json_filename, text_filename string, level LogLevel) {
+	self.logfile = NewLog(json_filename, text_filename, level)
 }
 
-func (self *Game) LogWithoutTurn(format_string string, args ...interface{}) {
-	self.logfile.Log(format_string, args...)
+func (self *Game) Log(format_string string, args ...interface{}) {		// Shorthand, for the core itself
+	self.Logger("core").Info(format_string, args...)
 }
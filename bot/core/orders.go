@@ -0,0 +1,123 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// An Order is what we tell a ship to do this turn. The zero value is NO_ORDER, i.e. nothing is sent for the ship.
+// Orders are only turned into the engine's command strings in RawOutput(), which is also where any angle
+// message (for the Chlorine viewer) gets encoded.
+
+type Order struct {
+	Type			OrderType
+	Speed			int					// THRUST only
+	Degrees			int					// THRUST only; always 0-359
+	Planet			int					// DOCK only
+}
+
+func Thrust(speed, degrees int) Order {
+	for degrees < 0 { degrees += 360 }; degrees %= 360
+	return Order{Type: THRUST, Speed: speed, Degrees: degrees}
+}
+
+func Dock(planet_id int) Order {
+	return Order{Type: DOCK, Planet: planet_id}
+}
+
+func Undock() Order {
+	return Order{Type: UNDOCK}
+}
+
+func Hold() Order {
+	return Order{Type: HOLD}
+}
+
+func (self Order) Course() (int, int) {					// Speed and degrees; 0, 0 for anything but a thrust.
+	if self.Type == THRUST {
+		return self.Speed, self.Degrees
+	}
+	return 0, 0
+}
+
+func (self Order) Stationary() bool {					// Note that NO_ORDER counts as stationary.
+	return self.Type != THRUST || self.Speed == 0
+}
+
+func (self Order) Command(sid int, message int) string {
+
+	// The engine's command string. We put some extra info into the angle, which we can see in the
+	// Chlorine replayer. Messages outside 0-180 are ignored.
+
+	switch self.Type {
+
+	case HOLD: fallthrough
+	case THRUST:
+
+		speed, degrees := self.Course()
+		if message >= 0 && message <= 180 {
+			degrees += (message + 1) * 360
+		}
+		return fmt.Sprintf("t %d %d %d", sid, speed, degrees)
+
+	case DOCK:
+
+		return fmt.Sprintf("d %d %d", sid, self.Planet)
+
+	case UNDOCK:
+
+		return fmt.Sprintf("u %d", sid)
+	}
+
+	return ""
+}
+
+func DecodeAngle(angle int) (int, int) {
+
+	// The inverse of what Command() does to a thrust angle: returns the real degrees, and the message (-1 if none).
+
+	if angle < 360 {
+		return angle, -1
+	}
+	return angle % 360, angle / 360 - 1
+}
+
+func CommandMessages(commands string) map[int]int {
+
+	// Decodes the messages hidden in a command string's thrusts (as a bot sends it): Ship ID --> message.
+
+	ret := make(map[int]int)
+	tokens := strings.Fields(commands)
+
+	for i := 0; i + 3 < len(tokens); i++ {
+		if tokens[i] != "t" {
+			continue
+		}
+		sid, err1 := strconv.Atoi(tokens[i + 1])
+		angle, err2 := strconv.Atoi(tokens[i + 3])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		if _, message := DecodeAngle(angle); message >= 0 {
+			ret[sid] = message
+		}
+		i += 3
+	}
+
+	return ret
+}
+
+func (self Order) String() string {
+	switch self.Type {
+	case HOLD:
+		return "hold"
+	case THRUST:
+		return fmt.Sprintf("thrust %d %d", self.Speed, self.Degrees)
+	case DOCK:
+		return fmt.Sprintf("dock %d", self.Planet)
+	case UNDOCK:
+		return "undock"
+	}
+	return "none"
+}
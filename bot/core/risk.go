@@ -0,0 +1,182 @@
+package core
+
+// Weapons fire at Time 0 is almost entirely predictable: every undocked ship with enemies within range shoots
+// them, splitting its damage. "Almost" because a ship that docks this turn doesn't shoot, and we can't know who
+// will (except that we never dock with enemies in range). Later in the turn, ships that weren't in range may
+// close in and fight, or may not. So instead of flags saying who fires and who dies, every ship gets chances:
+//
+//   - FireChance:     that it fires at Time 0 (i.e. it has targets, and doesn't dock instead).
+//   - DoomChance:     that it dies at Time 0, before it can move or collide with anything.
+//   - DeathChance:    that it dies at some point this turn, counting shots from later in the turn too.
+//   - ExpectedDamage: the mean damage it takes this turn.
+//
+// Any enemy ship able to dock is taken to do so with DOCK_CHANCE. (Undocking makes no difference this turn;
+// undocking ships can't move or shoot.) A mobile ship with no enemy in range at Time 0 may instead fire at one
+// it can reach during the turn, with a chance falling linearly from LATE_FIGHT_CHANCE at the edge of weapons
+// range to 0 at the edge of reach, and with all of its damage, since it fires only once. All shots are taken
+// to be independent; with that, the chance of death is exact.
+//
+// The two chances are guesses, not fitted to anything. Ships with enemies in range rarely dock, so DOCK_CHANCE
+// is small; LATE_FIGHT_CHANCE is likewise a rough figure and could do with measuring.
+//
+// Code that needs a yes or no compares these with one of the RISK thresholds, which say how sure we need to be
+// for each kind of decision.
+
+const (
+	DOCK_CHANCE = 0.05					// Chance that an enemy ship that can dock this turn does so
+	LATE_FIGHT_CHANCE = 0.08			// Chance that 2 ships just out of range close in and fight this turn
+
+	RISK_FIRING = 0.5					// FireChance at which a ship counts as firing at Time 0
+	RISK_NAV_IGNORE = 0.8				// DoomChance at which navigation can ignore a ship; it won't be there to hit
+	RISK_TARGET_IGNORE = 0.6			// DoomChance at which a ship isn't worth sending anyone to kill
+)
+
+type risk_shot struct {
+	damage			int
+	chance			float64
+}
+
+func (self *Game) PredictRisk() {
+
+	all_ships := self.AllShips()
+
+	targets := make(map[int][]*Ship)			// Ship ID --> ships in range at Time 0
+	shots_0 := make(map[int][]risk_shot)		// Ship ID --> shots coming in at Time 0
+	shots_late := make(map[int][]risk_shot)		// Ship ID --> shots maybe coming in later
+
+	for _, ship := range all_ships {
+
+		ship.FireChance = 0
+		ship.DoomChance = 0
+		ship.DeathChance = 0
+		ship.ExpectedDamage = 0
+
+		if ship.DockedStatus != UNDOCKED {
+			continue
+		}
+
+		for _, other := range self.ShipsWithin(ship.X, ship.Y, WEAPON_RANGE + SHIP_RADIUS * 2) {
+			if other.Owner != ship.Owner {
+				targets[ship.Id] = append(targets[ship.Id], other)
+			}
+		}
+	}
+
+	for _, ship := range all_ships {
+
+		if ship.DockedStatus != UNDOCKED {
+			continue
+		}
+
+		shoots := 1 - self.dock_chance(ship)
+
+		if len(targets[ship.Id]) > 0 {
+
+			ship.FireChance = shoots
+
+			damage := WEAPON_DAMAGE / len(targets[ship.Id])		// Right? A straight up integer truncation?
+
+			for _, target := range targets[ship.Id] {
+				shots_0[target.Id] = append(shots_0[target.Id], risk_shot{damage, shoots})
+			}
+
+			continue
+		}
+
+		// Nothing in range now. What might it reach? Each possible fight is weighted by how close it is, and
+		// if the weights add up to more than 1, they're shared out (it can only fire once)...
+
+		var candidates []*Ship
+		var weights []float64
+		var total float64
+
+		for _, other := range self.ShipsWithin(ship.X, ship.Y, WEAPON_RANGE + SHIP_RADIUS * 2 + MAX_SPEED * 2) {
+
+			if other.Owner == ship.Owner {
+				continue
+			}
+
+			reach := WEAPON_RANGE + SHIP_RADIUS * 2 + MAX_SPEED
+			if other.DockedStatus == UNDOCKED {
+				reach += MAX_SPEED
+			}
+
+			d := ship.Dist(other)
+
+			if d >= reach {
+				continue
+			}
+
+			weight := LATE_FIGHT_CHANCE * (reach - d) / (reach - WEAPON_RANGE - SHIP_RADIUS * 2)
+
+			candidates = append(candidates, other)
+			weights = append(weights, weight)
+			total += weight
+		}
+
+		for n, other := range candidates {
+			shots_late[other.Id] = append(shots_late[other.Id], risk_shot{WEAPON_DAMAGE, shoots * weights[n] / MaxFloat(1, total)})
+		}
+	}
+
+	for _, ship := range all_ships {
+
+		for _, shot := range shots_0[ship.Id] {
+			ship.ExpectedDamage += float64(shot.damage) * shot.chance
+		}
+		for _, shot := range shots_late[ship.Id] {
+			ship.ExpectedDamage += float64(shot.damage) * shot.chance
+		}
+
+		ship.DoomChance = death_chance(ship.HP, shots_0[ship.Id])
+		ship.DeathChance = death_chance(ship.HP, append(shots_0[ship.Id], shots_late[ship.Id]...))
+	}
+}
+
+func (self *Game) dock_chance(ship *Ship) float64 {
+
+	// We never dock with enemies in range, so our own ships certainly don't.
+
+	if ship.Owner == self.pid || ship.DockedStatus != UNDOCKED {
+		return 0
+	}
+
+	planet := self.ClosestPlanet(ship)
+
+	if planet != nil && ship.CanDock(planet) {
+		return DOCK_CHANCE
+	}
+
+	return 0
+}
+
+func death_chance(hp int, shots []risk_shot) float64 {
+
+	// The chance that the total damage from independent shots reaches hp; by tracking the chance of each
+	// possible total so far, with everything at hp or more lumped together.
+
+	if hp <= 0 {
+		return 1
+	}
+
+	dist := make([]float64, hp + 1)
+	dist[0] = 1
+
+	for _, shot := range shots {
+
+		next := make([]float64, hp + 1)
+		next[hp] = dist[hp]
+
+		for total := 0; total < hp; total++ {
+			if dist[total] == 0 {
+				continue
+			}
+			next[total] += dist[total] * (1 - shot.chance)
+			next[Min(hp, total + shot.damage)] += dist[total] * shot.chance
+		}
+
+		dist = next
+	}
+
+	return dist[hp]
+}
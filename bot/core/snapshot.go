@@ -0,0 +1,356 @@
+package core
+
+import (
+	"io/ioutil"
+	"math"
+	"sort"
+	"time"
+)
+
+// Parse() updates Ships and Planets in place (old ones get HP 0) so that anything holding a pointer sees the
+// new state. That's right for the live game but means it can't be branched. Snapshot() and Apply() instead make
+// a Game with its own Ships and Planets, whose caches and AI maps are rebuilt from scratch, so search code can
+// explore futures without touching the real thing.
+//
+// Neither kind of copy can Parse(); they have no token parser, and their output goes nowhere. They share the
+// live Game's logfile and Budget, since any search is happening during the live turn.
+
+func (self *Game) Snapshot() *Game {
+
+	ret := new(Game)
+	*ret = *self
+
+	ret.token_parser = nil
+	ret.tracer = nil								// Decisions made about a copy aren't traced
+	ret.out = ioutil.Discard
+
+	ret.shipMap = make(map[int]*Ship)
+	ret.planetMap = make(map[int]*Planet)
+
+	for sid, ship := range self.shipMap {
+		new_ship := new(Ship)
+		*new_ship = *ship
+		new_ship.ClosestEnemy = nil					// Set by build_caches()
+		ret.shipMap[sid] = new_ship
+	}
+
+	for plid, planet := range self.planetMap {
+		new_planet := new(Planet)
+		*new_planet = *planet
+		ret.planetMap[plid] = new_planet
+	}
+
+	ret.cumulativeShips = make(map[int]int)
+	for pid, count := range self.cumulativeShips {
+		ret.cumulativeShips[pid] = count
+	}
+
+	ret.lastownerMap = make(map[int]int)
+	for plid, owner := range self.lastownerMap {
+		ret.lastownerMap[plid] = owner
+	}
+
+	ret.orders = make(map[int]Order)
+	for sid, order := range self.orders {
+		ret.orders[sid] = order
+	}
+
+	ret.messages = make(map[int]int)
+	for sid, message := range self.messages {
+		ret.messages[sid] = message
+	}
+
+	ret.budget_tails = append([]time.Duration(nil), self.budget_tails...)
+
+	ret.rebuild_maps()
+	return ret
+}
+
+func (self *Game) Orders() map[int]Order {
+
+	// A copy of all orders given so far this turn, e.g. to hand to Apply().
+
+	ret := make(map[int]Order)
+	for sid, order := range self.orders {
+		ret[sid] = order
+	}
+	return ret
+}
+
+func (self *Game) Apply(orders map[int]Order) *Game {
+
+	// Returns a new Game, one turn on from this one, with the given orders (Ship ID --> Order, for any player)
+	// carried out. Ships without an order hold still. This Game is left alone.
+	//
+	// This is the engine's turn with the expensive parts left out: there is no production, spawning, planet
+	// damage, or explosions. genetic.Advance() does the whole thing, if needed.
+
+	ret := self.Snapshot()
+
+	ret.turn++
+	ret.orders = make(map[int]Order)
+	ret.messages = make(map[int]int)
+
+	// Positions at the start of the turn, for Dx and Dy later...
+
+	start_x := make(map[int]float64)
+	start_y := make(map[int]float64)
+
+	for _, ship := range ret.AllShips() {
+		start_x[ship.Id] = ship.X
+		start_y[ship.Id] = ship.Y
+	}
+
+	// Docking and undocking. The first ship (by ID) to dock at an unowned planet claims it...
+
+	for _, ship := range ret.AllShips() {
+
+		order := orders[ship.Id]
+
+		switch order.Type {
+
+		case DOCK:
+
+			planet, ok := ret.planetMap[order.Planet]
+
+			if ok && ship.DockedStatus == UNDOCKED && ship.CanDock(planet) && len(ret.dockMap[planet.Id]) < planet.DockingSpots {
+				ship.DockedStatus = DOCKING
+				ship.DockedPlanet = planet.Id
+				ship.DockingProgress = DOCK_TURNS
+				planet.Owned = true
+				planet.Owner = ship.Owner
+				ret.dockMap[planet.Id] = append(ret.dockMap[planet.Id], ship)
+			}
+
+		case UNDOCK:
+
+			if ship.DockedStatus == DOCKED {
+				ship.DockedStatus = UNDOCKING
+				ship.DockingProgress = DOCK_TURNS
+			}
+		}
+	}
+
+	// Everything else happens at the time it happens, as in the engine: ships fire once, at the first moment an
+	// enemy is in range, splitting the damage between all enemies in range then; ships that touch each other
+	// both die, as do ships that touch planets...
+
+	vel_x := make(map[int]float64)
+	vel_y := make(map[int]float64)
+
+	for _, ship := range ret.AllShips() {
+		if ship.DockedStatus == UNDOCKED {
+			speed, degrees := orders[ship.Id].Course()
+			vel_x[ship.Id], vel_y[ship.Id] = Projection(0, 0, float64(speed), degrees)
+		}
+	}
+
+	var events []*apply_event
+
+	all_ships := ret.AllShips()
+
+	for i, ship_a := range all_ships {
+
+		for _, ship_b := range all_ships[i + 1:] {
+
+			if ship_a.Dist(ship_b) > MAX_SPEED * 2 + WEAPON_RANGE + SHIP_RADIUS * 2 {
+				continue
+			}
+
+			if ship_a.Owner != ship_b.Owner && (ship_a.CanMove() || ship_b.CanMove()) {
+				t, ok := CollisionTime(WEAPON_RANGE + SHIP_RADIUS * 2,
+					ship_a.X, ship_a.Y, vel_x[ship_a.Id], vel_y[ship_a.Id], ship_b.X, ship_b.Y, vel_x[ship_b.Id], vel_y[ship_b.Id])
+				if ok && t >= 0 && t <= 1 {
+					events = append(events, &apply_event{APPLY_ATTACK, ship_a, ship_b, t})
+				}
+			}
+
+			t, ok := CollisionTime(SHIP_RADIUS * 2,
+				ship_a.X, ship_a.Y, vel_x[ship_a.Id], vel_y[ship_a.Id], ship_b.X, ship_b.Y, vel_x[ship_b.Id], vel_y[ship_b.Id])
+			if ok && t >= 0 && t <= 1 {
+				events = append(events, &apply_event{APPLY_SHIP_COLLISION, ship_a, ship_b, t})
+			}
+		}
+
+		if vel_x[ship_a.Id] == 0 && vel_y[ship_a.Id] == 0 {
+			continue
+		}
+
+		for _, planet := range ret.AllPlanets() {
+			t, ok := CollisionTime(planet.Radius + SHIP_RADIUS,
+				ship_a.X, ship_a.Y, vel_x[ship_a.Id], vel_y[ship_a.Id], planet.X, planet.Y, 0, 0)
+			if ok && t >= 0 && t <= 1 {
+				events = append(events, &apply_event{APPLY_PLANET_COLLISION, ship_a, nil, t})
+			}
+		}
+	}
+
+	sort.SliceStable(events, func(a, b int) bool {
+		return events[a].t < events[b].t
+	})
+
+	fired := make(map[int]bool)
+
+	for n := 0; n < len(events); {
+
+		// All events at the same moment happen together...
+
+		targets := make(map[*Ship][]*Ship)
+
+		k := n
+		for k < len(events) && events[k].t == events[n].t {
+
+			event := events[k]
+			k++
+
+			if event.ship_a.HP <= 0 || (event.ship_b != nil && event.ship_b.HP <= 0) {
+				continue
+			}
+
+			switch event.what {
+
+			case APPLY_PLANET_COLLISION:
+				event.ship_a.HP = 0
+
+			case APPLY_SHIP_COLLISION:
+				event.ship_a.HP = 0
+				event.ship_b.HP = 0
+
+			case APPLY_ATTACK:
+				if fired[event.ship_a.Id] == false && event.ship_a.CanMove() {
+					targets[event.ship_a] = append(targets[event.ship_a], event.ship_b)
+				}
+				if fired[event.ship_b.Id] == false && event.ship_b.CanMove() {
+					targets[event.ship_b] = append(targets[event.ship_b], event.ship_a)
+				}
+			}
+		}
+
+		for ship, ship_targets := range targets {
+			fired[ship.Id] = true
+			for _, target := range ship_targets {
+				target.HP -= WEAPON_DAMAGE / len(ship_targets)
+			}
+		}
+
+		n = k
+	}
+
+	// Survivors get where they were going, unless it's off the map...
+
+	for _, ship := range all_ships {
+
+		if ship.HP <= 0 {
+			continue
+		}
+
+		ship.X += vel_x[ship.Id]
+		ship.Y += vel_y[ship.Id]
+
+		if ret.InBounds(ship.X, ship.Y) == false {
+			ship.HP = 0
+		}
+	}
+
+	// Remove the dead, and push docking along a step as the parser's fudge_dock_status() would...
+
+	for sid, ship := range ret.shipMap {
+
+		if ship.HP <= 0 {
+			ship.HP = 0
+			delete(ret.shipMap, sid)
+			continue
+		}
+
+		ship.fudge_dock_status()
+
+		if ship.DockedStatus == UNDOCKED {
+			ship.DockedPlanet = -1
+		}
+
+		ship.Dx = ship.X - start_x[sid]
+		ship.Dy = ship.Y - start_y[sid]
+		ship.LastSpeed = Round(math.Sqrt(ship.Dx * ship.Dx + ship.Dy * ship.Dy))
+		ship.LastAngle = Angle(start_x[sid], start_y[sid], ship.X, ship.Y)
+
+		ship.History.Push(ShipRecord{ret.turn, ship.X, ship.Y, ship.HP, ship.DockedStatus})
+	}
+
+	ret.raw = ""								// There's no engine string for a world that never happened.
+	ret.run_of_sames = 0
+
+	ret.rebuild_maps()
+	return ret
+}
+
+const (
+	APPLY_ATTACK = iota
+	APPLY_SHIP_COLLISION
+	APPLY_PLANET_COLLISION
+)
+
+type apply_event struct {
+	what			int
+	ship_a			*Ship
+	ship_b			*Ship				// nil for planet collisions
+	t				float64
+}
+
+func (self *Game) rebuild_maps() {
+
+	// Remake every map that holds Ships or Planets from the shipMap and planetMap alone, as Parse() would.
+
+	self.dockMap = make(map[int][]*Ship)
+	self.playershipMap = make(map[int][]*Ship)
+	self.playerplanetMap = make(map[int][]*Planet)
+
+	players_with_ships := make(map[int]bool)
+
+	for _, ship := range self.shipMap {
+
+		self.playershipMap[ship.Owner] = append(self.playershipMap[ship.Owner], ship)
+		players_with_ships[ship.Owner] = true
+
+		if ship.DockedStatus != UNDOCKED {
+			if _, ok := self.planetMap[ship.DockedPlanet]; ok {
+				self.dockMap[ship.DockedPlanet] = append(self.dockMap[ship.DockedPlanet], ship)
+			}
+		}
+	}
+
+	for pid := range self.playershipMap {
+		sort.Slice(self.playershipMap[pid], func(a, b int) bool {
+			return self.playershipMap[pid][a].Id < self.playershipMap[pid][b].Id
+		})
+	}
+
+	for _, planet := range self.planetMap {
+
+		sort.Slice(self.dockMap[planet.Id], func(a, b int) bool {
+			return self.dockMap[planet.Id][a].Id < self.dockMap[planet.Id][b].Id
+		})
+
+		planet.DockedShips = len(self.dockMap[planet.Id])
+
+		if planet.DockedShips == 0 {
+			planet.Owner = -1
+			planet.Owned = false
+		} else {
+			planet.Owned = true
+			planet.Owner = self.dockMap[planet.Id][0].Owner
+			self.lastownerMap[planet.Id] = planet.Owner
+		}
+
+		self.playerplanetMap[planet.Owner] = append(self.playerplanetMap[planet.Owner], planet)
+	}
+
+	for pid := range self.playerplanetMap {
+		sort.Slice(self.playerplanetMap[pid], func(a, b int) bool {
+			return self.playerplanetMap[pid][a].Id < self.playerplanetMap[pid][b].Id
+		})
+	}
+
+	self.currentPlayers = len(players_with_ships)
+
+	self.build_caches()
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"math"
+	"sort"
+)
+
+// A uniform grid over the map, holding every ship, rebuilt whenever the ships change (i.e. in build_caches()).
+// Proximity queries then only look at the few cells near the point of interest, instead of every ship in the
+// game, which matters late in 4 player games with hundreds of ships.
+//
+// Results are always sorted by ID (or by distance, then ID) so that using the index never changes the order
+// in which anything is considered, compared to looping over AllShips().
+
+const (
+	SPATIAL_CELL_SIZE = 16.0
+)
+
+type SpatialIndex struct {
+	cols			int
+	rows			int
+	cells			[][]*Ship				// Row-major. Ships in each cell sorted by ID.
+}
+
+func NewSpatialIndex(width, height int, ships []*Ship) *SpatialIndex {
+
+	ret := new(SpatialIndex)
+
+	ret.cols = int(math.Ceil(float64(width) / SPATIAL_CELL_SIZE))
+	ret.rows = int(math.Ceil(float64(height) / SPATIAL_CELL_SIZE))
+
+	if ret.cols < 1 { ret.cols = 1 }
+	if ret.rows < 1 { ret.rows = 1 }
+
+	ret.cells = make([][]*Ship, ret.cols * ret.rows)
+
+	sorted := append([]*Ship(nil), ships...)
+	sort.Slice(sorted, func(a, b int) bool {
+		return sorted[a].Id < sorted[b].Id
+	})
+
+	for _, ship := range sorted {
+		n := ret.cell_index(ret.cell_x(ship.X), ret.cell_y(ship.Y))
+		ret.cells[n] = append(ret.cells[n], ship)
+	}
+
+	return ret
+}
+
+func (self *SpatialIndex) cell_x(x float64) int {
+	return Max(0, Min(self.cols - 1, int(x / SPATIAL_CELL_SIZE)))
+}
+
+func (self *SpatialIndex) cell_y(y float64) int {
+	return Max(0, Min(self.rows - 1, int(y / SPATIAL_CELL_SIZE)))
+}
+
+func (self *SpatialIndex) cell_index(cx, cy int) int {
+	return cy * self.cols + cx
+}
+
+func (self *SpatialIndex) ShipsWithin(x, y, r float64) []*Ship {
+
+	// All ships whose centre is within distance r of the point (inclusive), sorted by ID.
+
+	var ret []*Ship
+
+	x1, x2 := self.cell_x(x - r), self.cell_x(x + r)
+	y1, y2 := self.cell_y(y - r), self.cell_y(y + r)
+
+	for cy := y1; cy <= y2; cy++ {
+		for cx := x1; cx <= x2; cx++ {
+			for _, ship := range self.cells[self.cell_index(cx, cy)] {
+				if Dist(x, y, ship.X, ship.Y) <= r {
+					ret = append(ret, ship)
+				}
+			}
+		}
+	}
+
+	sort.Slice(ret, func(a, b int) bool {
+		return ret[a].Id < ret[b].Id
+	})
+
+	return ret
+}
+
+func (self *SpatialIndex) NearestShips(x, y float64, k int, accept func(ship *Ship) bool) []*Ship {
+
+	// The k nearest ships to the point that pass the accept function (which can be nil), sorted by distance
+	// and then ID. The search radius grows until it holds k such ships; those must then be the nearest.
+
+	if k <= 0 {
+		return nil
+	}
+
+	max_r := Dist(0, 0, float64(self.cols), float64(self.rows)) * SPATIAL_CELL_SIZE
+
+	var candidates []*Ship
+
+	for r := SPATIAL_CELL_SIZE; ; r *= 2 {
+
+		candidates = nil
+
+		for _, ship := range self.ShipsWithin(x, y, r) {
+			if accept == nil || accept(ship) {
+				candidates = append(candidates, ship)
+			}
+		}
+
+		if len(candidates) >= k || r >= max_r {
+			break
+		}
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return Dist(x, y, candidates[a].X, candidates[a].Y) < Dist(x, y, candidates[b].X, candidates[b].Y)
+	})
+
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+
+	return candidates
+}
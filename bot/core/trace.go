@@ -0,0 +1,364 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A trace follows chosen ships of ours, on chosen turns, through every decision made about them: the problem
+// they were given, their danger and inhibition, what they planned and why, how the move resolver treated them,
+// and the order finally sent. Which ships and turns is set at runtime by a TraceSpec, e.g. from flags:
+//
+//     -traceships 3,5-9 -traceturns 10-40,100-
+//
+// Code adds steps to the trace through Game.Trace(), which returns nil when the ship isn't being traced on this
+// turn; all the TraceStep methods accept nil, so when tracing is off it costs next to nothing:
+//
+//     game.Trace(sid, "danger").With("inhibition", x).With("danger_ships", ids)
+//
+// Field values are turned into JSON as they're added (see json_value()) so later changes to them don't leak in.
+//
+// The trace is written by Send(), after the orders are validated, as one JSON line per traced ship per turn.
+// Since each line has the turn and the ship, the file can be stepped through alongside the game's replay;
+// ReadTrace() reads it back, e.g. for cmd/narrate's -trace flag.
+
+type TraceSpec struct {
+	ships			map[int]bool				// nil means every ship of ours
+	turns			[][2]int					// Inclusive ranges; none means every turn
+}
+
+func ParseTraceSpec(ships, turns string) (*TraceSpec, error) {
+
+	// Ships is "all" or a list of IDs and ranges; turns is a list of turns and ranges, where a range may be
+	// open-ended ("100-"), or "" for all turns.
+
+	ret := new(TraceSpec)
+
+	if strings.ToLower(strings.TrimSpace(ships)) != "all" {
+
+		ranges, err := parse_ranges(ships)
+		if err != nil {
+			return nil, fmt.Errorf("ParseTraceSpec(): ships: %v", err)
+		}
+
+		ret.ships = make(map[int]bool)
+
+		for _, r := range ranges {
+			if r[1] == -1 {
+				return nil, fmt.Errorf("ParseTraceSpec(): ships: open-ended range")
+			}
+			for sid := r[0]; sid <= r[1]; sid++ {
+				ret.ships[sid] = true
+			}
+		}
+	}
+
+	ranges, err := parse_ranges(turns)
+	if err != nil {
+		return nil, fmt.Errorf("ParseTraceSpec(): turns: %v", err)
+	}
+	ret.turns = ranges
+
+	return ret, nil
+}
+
+func parse_ranges(s string) ([][2]int, error) {
+
+	// "3,5-9,100-" --> [3 3] [5 9] [100 -1]
+
+	var ret [][2]int
+
+	for _, part := range strings.Split(s, ",") {
+
+		part = strings.TrimSpace(part)
+
+		if part == "" {
+			continue
+		}
+
+		var err error
+		var r [2]int
+
+		if dash := strings.Index(part, "-"); dash == -1 {
+			r[0], err = strconv.Atoi(part)
+			r[1] = r[0]
+		} else {
+			r[0], err = strconv.Atoi(strings.TrimSpace(part[:dash]))
+			if err == nil {
+				if strings.TrimSpace(part[dash + 1:]) == "" {
+					r[1] = -1
+				} else {
+					r[1], err = strconv.Atoi(strings.TrimSpace(part[dash + 1:]))
+				}
+			}
+		}
+
+		if err != nil || r[0] < 0 || (r[1] != -1 && r[1] < r[0]) {
+			return nil, fmt.Errorf("bad range %q", part)
+		}
+
+		ret = append(ret, r)
+	}
+
+	return ret, nil
+}
+
+func (self *TraceSpec) Wants(turn, sid int) bool {
+
+	if self == nil {
+		return false
+	}
+
+	if self.ships != nil && self.ships[sid] == false {
+		return false
+	}
+
+	if len(self.turns) == 0 {
+		return true
+	}
+
+	for _, r := range self.turns {
+		if turn >= r[0] && (r[1] == -1 || turn <= r[1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ---------------------------------------------------------------
+
+type TraceRecord struct {
+	Turn			int
+	Pid				int
+	Ship			int
+	X				float64
+	Y				float64
+	HP				int
+	Docked			DockedStatus
+	Steps			[]*TraceStep				// In the order they happened
+	Order			string						// As Order.String(), e.g. "thrust 7 90"
+	Message			int							// -1 for none
+}
+
+type TraceStep struct {
+	Stage			string
+	Fields			[]LogField					// Values are all json.RawMessage
+}
+
+func (self *TraceStep) With(key string, value interface{}) *TraceStep {
+	if self != nil {
+		self.Fields = append(self.Fields, LogField{key, json.RawMessage(json_value(value))})
+	}
+	return self
+}
+
+func (self *TraceRecord) JSON() []byte {
+
+	// Built by hand, as LogRecord.JSON() is, for the fixed key order.
+
+	var b bytes.Buffer
+
+	fmt.Fprintf(&b, `{"turn":%d,"pid":%d,"ship":%d,"x":%.4f,"y":%.4f,"hp":%d,"docked":%d,"steps":[`,
+		self.Turn, self.Pid, self.Ship, self.X, self.Y, self.HP, self.Docked)
+
+	for n, step := range self.Steps {
+		if n > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, `{"stage":%s`, json_value(step.Stage))
+		for _, field := range step.Fields {
+			fmt.Fprintf(&b, `,%s:%s`, json_value(field.Key), json_value(field.Value))
+		}
+		b.WriteString("}")
+	}
+
+	fmt.Fprintf(&b, `],"order":%s`, json_value(self.Order))
+
+	if self.Message >= 0 {
+		fmt.Fprintf(&b, `,"message":%d`, self.Message)
+	}
+
+	b.WriteString("}")
+	return b.Bytes()
+}
+
+// ---------------------------------------------------------------
+
+type Tracer struct {
+	filename		string
+	file			*os.File
+	spec			*TraceSpec
+	turn			int
+	records			map[int]*TraceRecord		// Ship ID --> this turn's record
+}
+
+func (self *Game) StartTrace(filename string, spec *TraceSpec) {
+	self.tracer = &Tracer{
+		filename: filename,
+		spec: spec,
+		records: make(map[int]*TraceRecord),
+	}
+}
+
+func (self *Game) Tracing(sid int) bool {
+	return self.tracer != nil && self.tracer.spec.Wants(self.turn, sid)
+}
+
+func (self *Game) Trace(sid int, stage string) *TraceStep {
+
+	if self.Tracing(sid) == false {
+		return nil
+	}
+
+	record := self.tracer.record(self, sid)
+
+	step := &TraceStep{Stage: stage}
+	record.Steps = append(record.Steps, step)
+	return step
+}
+
+func (self *Tracer) record(game *Game, sid int) *TraceRecord {
+
+	if self.turn != game.turn {							// Whatever was left from an unsent turn is dropped
+		self.turn = game.turn
+		self.records = make(map[int]*TraceRecord)
+	}
+
+	record, ok := self.records[sid]
+
+	if ok == false {
+		record = &TraceRecord{Turn: game.turn, Pid: game.pid, Ship: sid, Message: -1}
+		if ship, ok := game.shipMap[sid]; ok {
+			record.X, record.Y, record.HP, record.Docked = ship.X, ship.Y, ship.HP, ship.DockedStatus
+		}
+		self.records[sid] = record
+	}
+
+	return record
+}
+
+func (self *Game) flush_trace(no_messages bool) {
+
+	// Called by Send(). Every traced ship of ours gets a line, even if nothing was decided about it.
+
+	if self.tracer == nil {
+		return
+	}
+
+	for _, ship := range self.playershipMap[self.pid] {
+		if self.Tracing(ship.Id) {
+			record := self.tracer.record(self, ship.Id)
+			record.Order = self.orders[ship.Id].String()
+			record.Message = self.message_for_validation(ship.Id, no_messages)
+		}
+	}
+
+	var sids []int
+	for sid := range self.tracer.records {
+		sids = append(sids, sid)
+	}
+	sort.Ints(sids)
+
+	if len(sids) > 0 && self.tracer.file == nil {
+		self.tracer.file = open_log(self.tracer.filename)
+	}
+
+	if self.tracer.file != nil {
+		for _, sid := range sids {
+			self.tracer.file.Write(append(self.tracer.records[sid].JSON(), '\n'))
+		}
+	}
+
+	self.tracer.records = make(map[int]*TraceRecord)
+}
+
+// ---------------------------------------------------------------
+
+func ReadTrace(filename string) ([]*TraceRecord, error) {
+
+	// Reads a trace file back. Step fields come back sorted by key, since JSON objects don't keep their order.
+
+	infile, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer infile.Close()
+
+	var ret []*TraceRecord
+
+	scanner := bufio.NewScanner(infile)
+	scanner.Buffer(make([]byte, 1024 * 1024), 64 * 1024 * 1024)
+
+	for line_number := 1; scanner.Scan(); line_number++ {
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var raw struct {
+			Turn		int							`json:"turn"`
+			Pid			int							`json:"pid"`
+			Ship		int							`json:"ship"`
+			X			float64						`json:"x"`
+			Y			float64						`json:"y"`
+			HP			int							`json:"hp"`
+			Docked		int							`json:"docked"`
+			Steps		[]map[string]json.RawMessage	`json:"steps"`
+			Order		string						`json:"order"`
+			Message		*int						`json:"message"`
+		}
+
+		err = json.Unmarshal(line, &raw)
+		if err != nil {
+			return nil, fmt.Errorf("ReadTrace(): %s line %d: %v", filename, line_number, err)
+		}
+
+		record := &TraceRecord{
+			Turn: raw.Turn,
+			Pid: raw.Pid,
+			Ship: raw.Ship,
+			X: raw.X,
+			Y: raw.Y,
+			HP: raw.HP,
+			Docked: DockedStatus(raw.Docked),
+			Order: raw.Order,
+			Message: -1,
+		}
+
+		if raw.Message != nil {
+			record.Message = *raw.Message
+		}
+
+		for _, m := range raw.Steps {
+
+			step := new(TraceStep)
+			json.Unmarshal(m["stage"], &step.Stage)
+
+			var keys []string
+			for key := range m {
+				if key != "stage" {
+					keys = append(keys, key)
+				}
+			}
+			sort.Strings(keys)
+
+			for _, key := range keys {
+				step.Fields = append(step.Fields, LogField{key, m[key]})
+			}
+
+			record.Steps = append(record.Steps, step)
+		}
+
+		ret = append(ret, record)
+	}
+
+	return ret, scanner.Err()
+}
@@ -0,0 +1,231 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// A transcript is a record of a bot's session with the engine, one line per line of traffic:
+//
+//     > ...     something the engine sent (the init block is 3 lines, each turn is 1 line)
+//     < ...     something the bot sent (its name, then its commands each turn)
+//     # k v     a note, e.g. the RNG seed
+//
+// The file is written as we go, unbuffered, so that a crash still leaves the fatal turn's input on disk.
+
+type TranscriptRecorder struct {
+	inner			Transport
+	outfile			*os.File
+	in_buf			[]byte
+	out_buf			[]byte
+}
+
+func NewTranscriptRecorder(inner Transport, filename string) (*TranscriptRecorder, error) {
+
+	outfile, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TranscriptRecorder{
+		inner: inner,
+		outfile: outfile,
+	}, nil
+}
+
+func (self *TranscriptRecorder) Note(key, value string) {
+	fmt.Fprintf(self.outfile, "# %s %s\n", key, value)
+}
+
+func (self *TranscriptRecorder) Read(p []byte) (int, error) {
+	n, err := self.inner.Read(p)
+	self.in_buf = self.record(self.in_buf, p[:n], "> ")
+	return n, err
+}
+
+func (self *TranscriptRecorder) Write(p []byte) (int, error) {
+	n, err := self.inner.Write(p)
+	self.out_buf = self.record(self.out_buf, p[:n], "< ")
+	return n, err
+}
+
+func (self *TranscriptRecorder) record(buf []byte, p []byte, prefix string) []byte {
+
+	// Write whatever complete lines we have, and return the leftovers.
+
+	buf = append(buf, p...)
+
+	for {
+		i := bytes.IndexByte(buf, '\n')
+		if i == -1 {
+			return buf
+		}
+		fmt.Fprintf(self.outfile, "%s%s\n", prefix, strings.TrimRight(string(buf[:i]), "\r"))
+		buf = buf[i + 1:]
+	}
+}
+
+func (self *TranscriptRecorder) Close() {
+	self.outfile.Close()
+}
+
+// ---------------------------------------
+
+// The TranscriptPlayer stands in for the engine, feeding the bot a transcript's input and comparing
+// the bot's replies with the recorded ones. Note that the bot's name isn't compared (it contains the
+// command line flags), and that GA turns can legitimately differ, since the GA is time-limited.
+
+type TranscriptPlayer struct {
+	input			*bytes.Reader
+	messages		int					// How many messages the engine sent (the init block counts as 1)
+	expected		[]string
+	notes			map[string]string
+	replies			int
+	write_buf		[]byte
+	report			io.Writer
+	Mismatches		int
+}
+
+func NewTranscriptPlayer(filename string, report io.Writer) (*TranscriptPlayer, error) {
+
+	infile, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer infile.Close()
+
+	ret := &TranscriptPlayer{
+		notes: make(map[string]string),
+		report: report,
+	}
+
+	var input []byte
+	input_lines := 0
+
+	scanner := bufio.NewScanner(infile)
+	scanner.Buffer(make([]byte, 1024 * 1024), 64 * 1024 * 1024)		// World lines can be long.
+
+	for scanner.Scan() {
+
+		line := scanner.Text()
+
+		switch {
+
+		case strings.HasPrefix(line, "> "):
+			input = append(input, line[2:]...)
+			input = append(input, '\n')
+			input_lines++
+
+		case strings.HasPrefix(line, "< "):
+			ret.expected = append(ret.expected, line[2:])
+
+		case strings.HasPrefix(line, "# "):
+			fields := strings.SplitN(line[2:], " ", 2)
+			if len(fields) == 2 {
+				ret.notes[fields[0]] = fields[1]
+			}
+		}
+	}
+
+	if scanner.Err() != nil {
+		return nil, scanner.Err()
+	}
+
+	if input_lines < 3 {
+		return nil, fmt.Errorf("%s: no init block", filename)
+	}
+
+	ret.input = bytes.NewReader(input)
+	ret.messages = input_lines - 2
+
+	return ret, nil
+}
+
+func (self *TranscriptPlayer) Note(key string) (string, bool) {
+	value, ok := self.notes[key]
+	return value, ok
+}
+
+func (self *TranscriptPlayer) Finished() bool {					// i.e. the bot has replied to every message.
+	return self.replies >= self.messages
+}
+
+func (self *TranscriptPlayer) Read(p []byte) (int, error) {
+	return self.input.Read(p)
+}
+
+func (self *TranscriptPlayer) Write(p []byte) (int, error) {
+
+	self.write_buf = append(self.write_buf, p...)
+
+	for {
+		i := bytes.IndexByte(self.write_buf, '\n')
+		if i == -1 {
+			break
+		}
+		self.compare(string(self.write_buf[:i]))
+		self.write_buf = self.write_buf[i + 1:]
+	}
+
+	return len(p), nil
+}
+
+func (self *TranscriptPlayer) compare(reply string) {
+
+	// Reply 0 is the name; reply n is the commands for turn n - 1.
+
+	n := self.replies
+	self.replies++
+
+	if n == 0 {
+		return
+	}
+
+	if n >= len(self.expected) {
+		fmt.Fprintf(self.report, "Turn %d: (nothing recorded)\n   now: %s\n", n - 1, reply)
+		return
+	}
+
+	if sorted_commands(reply) != sorted_commands(self.expected[n]) {
+		self.Mismatches++
+		fmt.Fprintf(self.report, "Turn %d: differs\n   was: %s\n   now: %s\n", n - 1, self.expected[n], reply)
+	}
+}
+
+func (self *TranscriptPlayer) Summary() string {
+	return fmt.Sprintf("Played %d turns; %d differed from the recording.", Max(0, self.replies - 1), self.Mismatches)
+}
+
+func sorted_commands(s string) string {
+
+	// Order of commands doesn't matter, since it comes from map iteration.
+
+	var commands []string
+	tokens := strings.Fields(s)
+
+	for i := 0; i < len(tokens); i++ {
+		n := 1
+		switch tokens[i] {
+		case "t":
+			n = 4
+		case "d":
+			n = 3
+		case "u":
+			n = 2
+		}
+		end := i + n
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+		commands = append(commands, strings.Join(tokens[i:end], " "))
+		i = end - 1
+	}
+
+	sort.Strings(commands)
+	return strings.Join(commands, " ")
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// A Transport is how the Game talks to the engine: it reads the engine's messages and writes our
+// replies. Anything that reads and writes will do, e.g. a net.Conn, or a pair of pipes via NewTransport().
+
+type Transport interface {
+	io.Reader
+	io.Writer
+}
+
+type rw_transport struct {
+	io.Reader
+	io.Writer
+}
+
+func NewTransport(r io.Reader, w io.Writer) Transport {
+	return &rw_transport{r, w}
+}
+
+func StdioTransport() Transport {
+	return NewTransport(os.Stdin, os.Stdout)
+}
+
+func ReadOnlyTransport(r io.Reader) Transport {					// Replies are discarded.
+	return NewTransport(r, ioutil.Discard)
+}
+
+// ---------------------------------------
+
+// The ChanTransport is an in-memory Transport, for running a bot in the same process as whatever is
+// playing the engine. The engine side calls Post() with each message and Receive() for each reply.
+// The bot side blocks on reading until there's a message.
+
+type ChanTransport struct {
+	to_bot			chan string
+	from_bot		chan string
+	read_buf		[]byte
+	write_buf		[]byte
+}
+
+func NewChanTransport() *ChanTransport {
+	return &ChanTransport{
+		to_bot: make(chan string, 16),
+		from_bot: make(chan string, 16),
+	}
+}
+
+func (self *ChanTransport) Post(message string) {
+	self.to_bot <- message + "\n"
+}
+
+func (self *ChanTransport) Receive() string {
+	return <-self.from_bot
+}
+
+func (self *ChanTransport) Close() {						// The bot will get EOF once it has read everything.
+	close(self.to_bot)
+}
+
+func (self *ChanTransport) Read(p []byte) (int, error) {
+	for len(self.read_buf) == 0 {
+		s, ok := <-self.to_bot
+		if ok == false {
+			return 0, io.EOF
+		}
+		self.read_buf = []byte(s)
+	}
+	n := copy(p, self.read_buf)
+	self.read_buf = self.read_buf[n:]
+	return n, nil
+}
+
+func (self *ChanTransport) Write(p []byte) (int, error) {
+
+	// Each complete line written is one reply.
+
+	self.write_buf = append(self.write_buf, p...)
+
+	for {
+		i := bytes.IndexByte(self.write_buf, '\n')
+		if i == -1 {
+			break
+		}
+		self.from_bot <- string(self.write_buf[:i])
+		self.write_buf = self.write_buf[i + 1:]
+	}
+
+	return len(p), nil
+}
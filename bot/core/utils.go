@@ -4,8 +4,6 @@ import (
 	"crypto/sha1"
 	"fmt"
 	"math"
-	"strconv"
-	"strings"
 )
 
 func ShipsWillCollide(ship_a *Ship, speed_a, angle_a, msg1 int, ship_b *Ship, speed_b, angle_b, msg2 int) bool {
@@ -34,6 +32,64 @@ func ShipsWillCollide(ship_a *Ship, speed_a, angle_a, msg1 int, ship_b *Ship, sp
 	return IntersectSegmentCircle(ship_a.X, ship_a.Y, endx_adjusted, endy_adjusted, ship_b.X, ship_b.Y, SHIP_RADIUS * 2)
 }
 
+func CollisionTime(r, x1, y1, vel_x1, vel_y1, x2, y2, vel_x2, vel_y2 float64) (float64, bool) {
+
+	// https://github.com/HaliteChallenge/Halite-II/blob/master/environment/core/SimulationEvent.cpp#L100
+	//
+	// With credit to Ben Spector
+	// Simplified derivation:
+	// 1. Set up the distance between the two entities in terms of time,
+	//    the difference between their velocities and the difference between
+	//    their positions
+	// 2. Equate the distance equal to the event radius (max possible distance
+	//    they could be)
+	// 3. Solve the resulting quadratic
+
+	dx := x1 - x2
+	dy := y1 - y2
+	dvx := vel_x1 - vel_x2
+	dvy := vel_y1 - vel_y2
+
+	// Quadratic formula
+	a := dvx * dvx + dvy * dvy				// const auto a = std::pow(dvx, 2) + std::pow(dvy, 2);
+	b := 2 * (dx * dvx + dy * dvy)			// const auto b = 2 * (dx * dvx + dy * dvy);
+	c := dx * dx + dy * dy - r * r			// const auto c = std::pow(dx, 2) + std::pow(dy, 2) - std::pow(r, 2);
+
+	disc := b * b - 4 * a * c				// disc := std::pow(b, 2) - 4 * a * c;
+
+	if (a == 0.0) {
+		if (b == 0.0) {
+			if (c <= 0.0) {
+				// Implies r^2 >= dx^2 + dy^2 and the two are already colliding
+				return 0.0, true
+			}
+			return 0.0, false
+		}
+		t := -c / b
+		if (t >= 0.0) {
+			return t, true
+		}
+		return 0.0, false
+	} else if (disc == 0.0) {
+		// One solution
+		t := -b / (2 * a)
+		return t, true
+	} else if (disc > 0) {
+		t1 := -b + math.Sqrt(disc)
+		t2 := -b - math.Sqrt(disc)
+
+		if (t1 >= 0.0 && t2 >= 0.0) {
+			return MinFloat(t1, t2) / (2 * a), true
+		} else if (t1 <= 0.0 && t2 <= 0.0) {
+			return MaxFloat(t1, t2) / (2 * a), true
+		} else {
+			return 0.0, true
+		}
+	} else {
+		return 0.0, false
+	}
+}
+
 func IntersectSegmentCircle(startx, starty, endx, endy, circlex, circley, radius float64) bool {
 
 	// Based on the Python version, I have no idea how this works.
@@ -156,42 +212,6 @@ func Dist(x1, y1, x2, y2 float64) float64 {
 	return math.Sqrt(dx * dx + dy * dy)
 }
 
-func CourseFromString(s string) (int, int) {
-
-	tokens := strings.Fields(s)
-
-	if len(tokens) != 4 {			// t sid speed angle
-		return 0, 0
-	}
-
-	if tokens[0] != "t" {
-		return 0, 0
-	}
-
-	speed, err1 := strconv.Atoi(tokens[2])
-	degrees, err2 := strconv.Atoi(tokens[3])
-
-	if err1 != nil || err2 != nil {
-		return 0, 0
-	}
-
-	for degrees < 0 {				// FIXME: this is dumb, what's the mathematical way?
-		degrees += 360
-	}
-
-	degrees %= 360
-
-	return speed, degrees
-}
-
-func GetOrderType(s string) string {
-	if len(s) == 0 { return ""  }
-	if s[0] == 't' { return "t" }
-	if s[0] == 'd' { return "d" }
-	if s[0] == 'u' { return "u" }
-	return "?"
-}
-
 func HashFromString(datastring string) string {
 	data := []byte(datastring)
 	sum := sha1.Sum(data)
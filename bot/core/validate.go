@@ -0,0 +1,164 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+)
+
+// The last line of defence before orders are sent. Every order is checked against the rules, and anything
+// that breaks them is logged and replaced by something safe (usually holding still, or no order at all).
+// Whatever the planners got wrong, the engine should never see an illegal order, nor 2 of our ships colliding.
+
+type OrderProblem struct {
+	Sid				int
+	Reason			string
+	Was				Order
+	Now				Order
+}
+
+func (self *Game) ValidateOrders(no_messages bool) []*OrderProblem {
+
+	var problems []*OrderProblem
+
+	repair := func(sid int, reason string, now Order) {
+		problems = append(problems, &OrderProblem{sid, reason, self.orders[sid], now})
+		self.Logger("core").Ship(sid).With("was", self.orders[sid]).With("now", now).Warn("ValidateOrders(): %s", reason)
+		self.Trace(sid, "validate").With("reason", reason).With("was", self.orders[sid]).With("now", now)
+		self.SetOrder(sid, now)
+	}
+
+	var sids []int
+	for sid := range self.orders {
+		sids = append(sids, sid)
+	}
+	sort.Ints(sids)
+
+	// Orders for single ships...
+
+	for _, sid := range sids {
+
+		order := self.orders[sid]
+		ship, ok := self.shipMap[sid]
+
+		if ok == false || ship.Owner != self.pid {
+			repair(sid, "no such ship of ours", Order{})
+			continue
+		}
+
+		switch order.Type {
+
+		case HOLD: fallthrough
+		case THRUST:
+
+			if ship.DockedStatus != UNDOCKED {
+				repair(sid, "thrust while not undocked", Order{})
+				continue
+			}
+
+			if order.Speed < 0 {
+				repair(sid, "negative speed", Hold())
+				continue
+			}
+
+			if order.Speed > MAX_SPEED {
+				order = Thrust(MAX_SPEED, order.Degrees)
+				repair(sid, "speed above MAX_SPEED", order)
+			}
+
+			if order.Speed > 0 && self.CourseStaysInBounds(ship, order.Speed, order.Degrees) == false {
+				speed := order.Speed
+				for speed > 0 && self.CourseStaysInBounds(ship, speed, order.Degrees) == false {
+					speed--
+				}
+				if speed == 0 {
+					repair(sid, "course leaves the map", Hold())
+				} else {
+					repair(sid, "course leaves the map", Thrust(speed, order.Degrees))
+				}
+			}
+
+		case DOCK:
+
+			planet, ok := self.planetMap[order.Planet]
+
+			if ok == false || ship.DockedStatus != UNDOCKED || ship.CanDock(planet) == false {
+				if ship.DockedStatus == UNDOCKED {
+					repair(sid, "can't dock", Hold())
+				} else {
+					repair(sid, "can't dock", Order{})
+				}
+			}
+
+		case UNDOCK:
+
+			if ship.DockedStatus != DOCKED {
+				repair(sid, "undock while not docked", Order{})
+			}
+		}
+	}
+
+	// Collisions between our own ships. Every one of our ships is checked, even those navigation ignored as
+	// probably doomed, since they do sometimes survive. By now only undocked ships can have thrust orders,
+	// so the others are stationary obstacles. Whenever a moving ship would hit another ship, it
+	// holds still instead (if both move, the one with the higher ID holds). That can create new collisions
+	// with the ship that's now stationary, so go again until there are none.
+
+	ships := append([]*Ship(nil), self.playershipMap[self.pid]...)
+
+	sort.Slice(ships, func(a, b int) bool {
+		return ships[a].Id < ships[b].Id
+	})
+
+	for {
+
+		changed := false
+
+		for i, ship_a := range ships {
+
+			speed_a, degrees_a := self.orders[ship_a.Id].Course()
+			msg_a := self.message_for_validation(ship_a.Id, no_messages)
+
+			for _, ship_b := range ships[i + 1:] {
+
+				if ship_a.Dist(ship_b) > MAX_SPEED * 2 + SHIP_RADIUS * 2 {
+					continue
+				}
+
+				speed_b, degrees_b := self.orders[ship_b.Id].Course()
+
+				if speed_a == 0 && speed_b == 0 {
+					continue
+				}
+
+				msg_b := self.message_for_validation(ship_b.Id, no_messages)
+
+				if ShipsWillCollide(ship_a, speed_a, degrees_a, msg_a, ship_b, speed_b, degrees_b, msg_b) {
+					if speed_b > 0 {
+						repair(ship_b.Id, fmt.Sprintf("collides with our ship %d", ship_a.Id), Hold())
+					} else {
+						repair(ship_a.Id, fmt.Sprintf("collides with our ship %d", ship_b.Id), Hold())
+					}
+					changed = true
+					break
+				}
+			}
+		}
+
+		if changed == false {
+			break
+		}
+	}
+
+	return problems
+}
+
+func (self *Game) message_for_validation(sid int, no_messages bool) int {
+	if no_messages {
+		return -1
+	}
+	message, ok := self.messages[sid]
+	if ok == false {
+		return -1
+	}
+	return message
+}
@@ -1,6 +1,7 @@
 package genetic
 
 import (
+	"context"
 	"sort"
 	"time"
 
@@ -35,7 +36,7 @@ var chase_permutations_2v2 = [][]int{
 
 func EvolveRush(game *hal.Game, enemy_pid int, play_perfect bool) {
 
-	game.LogOnce("Entering EvolveRush() genetic algorithm!")
+	game.Logger("genetic").Once("enter").Info("Entering EvolveRush() genetic algorithm!")
 
 	var my_mutable_ships []*hal.Ship
 	var my_immutable_ships []*hal.Ship
@@ -55,19 +56,22 @@ func EvolveRush(game *hal.Game, enemy_pid int, play_perfect bool) {
 
 	start_time := time.Now()
 
+	ctx, cancel := game.Budget().Context()
+	defer cancel()
+
 	evolver := NewEvolver(game, my_mutable_ships, my_immutable_ships, enemy_ships, 10)
-	evolver.RunRushFight(15000, play_perfect)
+	evolver.RunRushFight(ctx, 15000, play_perfect)
 
 	msg := pil.MSG_SECRET_SAUCE; if play_perfect { msg = pil.MSG_PERFECT_SAUCE }
 	evolver.ExecuteGenome(msg)
 
-	game.Log("Score: %v (i: %v, dvn: %v, cs: %v, t: %v)",
-		evolver.genomes[0].score,
-		evolver.iterations_required,
-		evolver.genomes[0].score - evolver.null_score,
-		evolver.cold_swaps,
-		time.Now().Sub(start_time).Truncate(1 * time.Millisecond),
-	)
+	game.Logger("genetic").
+		With("score", evolver.genomes[0].score).
+		With("iterations", evolver.iterations_required).
+		With("dvn", evolver.genomes[0].score - evolver.null_score).
+		With("cold_swaps", evolver.cold_swaps).
+		With("ms", time.Now().Sub(start_time).Milliseconds()).
+		Info("EvolveRush() done")
 
 	for _, ship := range game.MyShips() {
 		if ship.DockedStatus != hal.UNDOCKED {
@@ -76,7 +80,7 @@ func EvolveRush(game *hal.Game, enemy_pid int, play_perfect bool) {
 	}
 }
 
-func (self *Evolver) RunRushFight(iterations int, play_perfect bool) {
+func (self *Evolver) RunRushFight(ctx context.Context, iterations int, play_perfect bool) {
 
 	const (
 		PANIC_RANGE = 30		// How far the enemy can get before we worry
@@ -401,7 +405,7 @@ func (self *Evolver) RunRushFight(iterations int, play_perfect bool) {
 
 							for _, enemy_ship := range real_enemy_ships {				// Must use real_enemy_ships, since sim enemies aren't present.
 
-								if enemy_ship.Doomed {
+								if enemy_ship.DoomChance >= hal.RISK_NAV_IGNORE {
 									continue				// No need to worry about getting to the right distance away from doomed ships.
 								}
 
@@ -499,8 +503,8 @@ func (self *Evolver) RunRushFight(iterations int, play_perfect bool) {
 			best_score = self.genomes[0].score
 		}
 
-		if time.Now().Sub(self.game.ParseTime()) > 1500 * time.Millisecond {
-			self.game.Log("Emergency timeout in RunRushFight() after %d iterations.", n)
+		if ctx.Err() != nil {
+			self.game.Logger("genetic").With("iterations", n).Warn("Emergency timeout in RunRushFight()")
 			return
 		}
 	}
@@ -62,7 +62,7 @@ func (self *Sim) Reset() {
 		// ship.id = real_ship.Id
 		// ship.owner = real_ship.Owner
 		// ship.dockedstatus = real_ship.DockedStatus
-		// ship.fires_at_time_0 = real_ship.Firing
+		// ship.fires_at_time_0 = real_ship.FireChance >= hal.RISK_FIRING
 	}
 }
 
@@ -246,7 +246,7 @@ func (self *Sim) Step() {
 					ship_b.actual_targets = append(ship_b.actual_targets, ship_a)
 				}
 
-			} else if event.what == PLANET_COLLISION {						// FIXME: if we use this for real sims, we need to do planet damage.
+			} else if event.what == PLANET_COLLISION {						// No planet damage. For real sims, use the TurnSim.
 
 				event.ship_a.hp = 0
 				event.ship_a.stupid_death = true
@@ -325,7 +325,7 @@ func SetupSim(game *hal.Game, relevant_ships []*hal.Ship) *Sim {
 			hp: ship.HP,
 			owner: ship.Owner,
 			id: ship.Id,
-			fires_at_time_0: ship.Firing,
+			fires_at_time_0: ship.FireChance >= hal.RISK_FIRING,
 			real_ship: ship,
 		})
 	}
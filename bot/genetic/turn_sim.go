@@ -0,0 +1,951 @@
+package genetic
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	hal "../core"
+)
+
+// The TurnSim is a complete simulation of a Halite II turn, as done by the official engine:
+// https://github.com/HaliteChallenge/Halite-II/blob/master/environment/core/Halite.cpp
+//
+// Unlike the Sim (which only cares about the fights the GA needs), it handles docking, production,
+// spawning, planet damage and explosions, weapon cooldown, and ships leaving the map. It is much
+// slower than the Sim, so isn't for use inside the GA's inner loop.
+//
+// Note that the state held is the engine's true state, not the "fudged" one that our parser makes.
+
+type TurnShip struct {
+	SimShip
+	docked_planet		int
+	docking_progress	int
+	cooldown			int
+}
+
+type TurnPlanet struct {
+	SimPlanet
+	id					int
+	hp					int
+	owned				bool
+	owner				int
+	docking_spots		int
+	production			int
+	docked_ships		[]*TurnShip
+	exploded			bool
+}
+
+type TurnEvent struct {
+	ship_a				*TurnShip
+	ship_b				*TurnShip
+	planet				*TurnPlanet
+	t					float64
+	what				EventType
+}
+
+type TurnSim struct {
+	width				int
+	height				int
+	turn				int
+	players				int
+	next_ship_id		int
+	planets				[]*TurnPlanet
+	ships				[]*TurnShip
+	damage_dealt		map[int]int			// Player ID --> total weapon damage dealt
+}
+
+// OrderSource gives the orders for every player for the sim's current turn.
+// The map is Player ID --> command string, exactly as a bot would send it to the engine.
+
+type OrderSource func(sim *TurnSim) map[int]string
+
+// --------------------------------------------------------------------
+
+func NewTurnSim(game *hal.Game) *TurnSim {
+
+	sim := new(TurnSim)
+
+	sim.width = game.Width()
+	sim.height = game.Height()
+	sim.turn = game.Turn()
+	sim.players = game.InitialPlayers()
+	sim.damage_dealt = make(map[int]int)
+
+	ship_lookup := make(map[int]*TurnShip)
+
+	for _, ship := range game.AllShips() {
+
+		new_ship := &TurnShip{
+			SimShip: SimShip{
+				SimEntity: SimEntity{
+					x: ship.X,
+					y: ship.Y,
+					radius: hal.SHIP_RADIUS,
+				},
+				ship_state: ALIVE,
+				weapon_state: READY,
+				dockedstatus: ship.DockedStatus,
+				owner: ship.Owner,
+				hp: ship.HP,
+				id: ship.Id,
+				real_ship: ship,
+			},
+			docked_planet: ship.DockedPlanet,
+			docking_progress: ship.DockingProgress,
+		}
+
+		// Undo the parser's fudge_dock_status() so that the sim's own docking step does the right thing.
+		// A ship that the parser moved from DOCKING to DOCKED is indistinguishable from a docked ship,
+		// but that doesn't matter since it will be docked before anything else happens.
+
+		switch new_ship.dockedstatus {
+		case hal.DOCKING: fallthrough
+		case hal.UNDOCKING:
+			new_ship.docking_progress++
+		case hal.DOCKED:
+			new_ship.docking_progress = 0
+		}
+
+		sim.ships = append(sim.ships, new_ship)
+		ship_lookup[ship.Id] = new_ship
+
+		if ship.Id >= sim.next_ship_id {
+			sim.next_ship_id = ship.Id + 1
+		}
+	}
+
+	// The engine never reuses IDs, so if the Game has seen the whole game, its count of
+	// every ship ever seen says where the IDs have got to, even if the latest ships are dead.
+
+	cumulative := 0
+	for pid := 0; pid < sim.players; pid++ {
+		cumulative += game.GetCumulativeShipCount(pid)
+	}
+	sim.next_ship_id = hal.Max(sim.next_ship_id, cumulative)
+
+	for _, planet := range game.AllPlanets() {
+
+		new_planet := &TurnPlanet{
+			SimPlanet: SimPlanet{
+				SimEntity{
+					x: planet.X,
+					y: planet.Y,
+					radius: planet.Radius,
+				},
+			},
+			id: planet.Id,
+			hp: planet.HP,
+			owned: planet.Owned,
+			owner: planet.Owner,
+			docking_spots: planet.DockingSpots,
+			production: planet.CurrentProduction,
+		}
+
+		for _, ship := range game.ShipsDockedAt(planet) {
+			new_planet.docked_ships = append(new_planet.docked_ships, ship_lookup[ship.Id])
+		}
+
+		sim.planets = append(sim.planets, new_planet)
+	}
+
+	return sim
+}
+
+func (self *TurnSim) Copy() *TurnSim {
+
+	ret := new(TurnSim)
+	*ret = *self
+
+	ret.ships = nil
+	ret.planets = nil
+
+	ret.damage_dealt = make(map[int]int)
+	for pid, damage := range self.damage_dealt {
+		ret.damage_dealt[pid] = damage
+	}
+
+	ship_lookup := make(map[*TurnShip]*TurnShip)
+
+	for _, ship := range self.ships {
+		new_ship := new(TurnShip)
+		*new_ship = *ship
+		new_ship.actual_targets = nil
+		ret.ships = append(ret.ships, new_ship)
+		ship_lookup[ship] = new_ship
+	}
+
+	for _, planet := range self.planets {
+		new_planet := new(TurnPlanet)
+		*new_planet = *planet
+		new_planet.docked_ships = nil
+		for _, ship := range planet.docked_ships {
+			new_planet.docked_ships = append(new_planet.docked_ships, ship_lookup[ship])
+		}
+		ret.planets = append(ret.planets, new_planet)
+	}
+
+	return ret
+}
+
+func (self *TurnSim) Turn() int { return self.turn }
+func (self *TurnSim) Width() int { return self.width }
+func (self *TurnSim) Height() int { return self.height }
+func (self *TurnSim) Players() int { return self.players }
+
+func (self *TurnSim) ShipCount(pid int) int {
+	count := 0
+	for _, ship := range self.ships {
+		if ship.owner == pid {
+			count++
+		}
+	}
+	return count
+}
+
+func (self *TurnSim) PlanetCount(pid int) int {
+	count := 0
+	for _, planet := range self.planets {
+		if planet.owned && planet.owner == pid {
+			count++
+		}
+	}
+	return count
+}
+
+func (self *TurnSim) DamageDealt(pid int) int {
+	return self.damage_dealt[pid]
+}
+
+func (self *TurnSim) Ships() []hal.Ship {
+
+	// Copies of the ships, in the engine's true (unfudged) state, sorted by ID.
+
+	var ret []hal.Ship
+
+	for _, ship := range self.ships {
+		docked_planet := -1
+		if ship.dockedstatus != hal.UNDOCKED {
+			docked_planet = ship.docked_planet
+		}
+		ret = append(ret, hal.Ship{
+			Id: ship.id,
+			Owner: ship.owner,
+			X: ship.x,
+			Y: ship.y,
+			HP: ship.hp,
+			DockedStatus: ship.dockedstatus,
+			DockedPlanet: docked_planet,
+			DockingProgress: ship.docking_progress,
+		})
+	}
+
+	sort.Slice(ret, func(a, b int) bool {
+		return ret[a].Id < ret[b].Id
+	})
+
+	return ret
+}
+
+func (self *TurnSim) Planets() []hal.Planet {
+
+	// Copies of the planets, sorted by ID. The docked ships can be found from Ships().
+
+	var ret []hal.Planet
+
+	for _, planet := range self.planets {
+		owner := -1
+		if planet.owned {
+			owner = planet.owner
+		}
+		ret = append(ret, hal.Planet{
+			Id: planet.id,
+			X: planet.x,
+			Y: planet.y,
+			HP: planet.hp,
+			Radius: planet.radius,
+			DockingSpots: planet.docking_spots,
+			CurrentProduction: planet.production,
+			Owned: planet.owned,
+			Owner: owner,
+			DockedShips: len(planet.docked_ships),
+		})
+	}
+
+	sort.Slice(ret, func(a, b int) bool {
+		return ret[a].Id < ret[b].Id
+	})
+
+	return ret
+}
+
+func (self *TurnSim) RemovePlayer(pid int) {
+
+	// What the engine does to a bot that times out or crashes.
+
+	for _, ship := range self.ships {
+		if ship.owner == pid && ship.ship_state == ALIVE {
+			self.kill_ship(ship)
+		}
+	}
+
+	self.remove_dead()
+}
+
+// --------------------------------------------------------------------
+
+func Advance(game *hal.Game, turns int, source OrderSource) *hal.Game {
+
+	// Advance the game by some number of turns, and return a new Game from the same player's point of view.
+
+	sim := NewTurnSim(game)
+
+	for n := 0; n < turns; n++ {
+		sim.Step(source(sim))
+	}
+
+	return sim.Game(game.Pid())
+}
+
+func (self *TurnSim) Game(pid int) *hal.Game {
+	init_string := fmt.Sprintf("%d %d %d ", pid, self.width, self.height)
+	return hal.NewGameFromString(init_string + self.WorldString(), self.turn)
+}
+
+func (self *TurnSim) WorldString() string {
+
+	// The world as the engine would send it to a bot.
+
+	var tokens []string
+
+	add := func(format_string string, args ...interface{}) {
+		tokens = append(tokens, fmt.Sprintf(format_string, args...))
+	}
+
+	add("%d", self.players)
+
+	for pid := 0; pid < self.players; pid++ {
+
+		var player_ships []*TurnShip
+
+		for _, ship := range self.ships {
+			if ship.owner == pid {
+				player_ships = append(player_ships, ship)
+			}
+		}
+
+		add("%d %d", pid, len(player_ships))
+
+		for _, ship := range player_ships {
+			docked_planet := 0
+			if ship.dockedstatus != hal.UNDOCKED {
+				docked_planet = ship.docked_planet
+			}
+			add("%d %f %f %d 0.0 0.0 %d %d %d %d",
+				ship.id, ship.x, ship.y, ship.hp, ship.dockedstatus, docked_planet, ship.docking_progress, ship.cooldown)
+		}
+	}
+
+	add("%d", len(self.planets))
+
+	for _, planet := range self.planets {
+
+		owned, owner := 0, 0
+		if planet.owned {
+			owned, owner = 1, planet.owner
+		}
+
+		add("%d %f %f %d %f %d %d 0 %d %d %d",
+			planet.id, planet.x, planet.y, planet.hp, planet.radius, planet.docking_spots, planet.production,
+			owned, owner, len(planet.docked_ships))
+
+		for _, ship := range planet.docked_ships {
+			add("%d", ship.id)
+		}
+	}
+
+	return strings.Join(tokens, " ")
+}
+
+// --------------------------------------------------------------------
+
+func (self *TurnSim) Step(commands map[int]string) {
+
+	// The order of things here follows the engine's process_next_frame().
+
+	self.turn++
+
+	self.process_docking()
+	self.process_orders(commands)
+	self.process_events()
+	self.process_movement()
+	self.process_production()
+	self.process_cooldowns()
+
+	self.remove_dead()
+}
+
+func (self *TurnSim) process_docking() {
+
+	for _, planet := range self.planets {
+
+		for i := 0; i < len(planet.docked_ships); i++ {
+
+			ship := planet.docked_ships[i]
+
+			switch ship.dockedstatus {
+
+			case hal.DOCKING:
+
+				ship.docking_progress--
+				if ship.docking_progress <= 0 {
+					ship.docking_progress = 0
+					ship.dockedstatus = hal.DOCKED
+				}
+
+			case hal.UNDOCKING:
+
+				ship.docking_progress--
+				if ship.docking_progress <= 0 {
+					ship.docking_progress = 0
+					ship.dockedstatus = hal.UNDOCKED
+					ship.docked_planet = -1
+					planet.docked_ships = append(planet.docked_ships[:i], planet.docked_ships[i+1:]...)
+					i--
+				}
+			}
+		}
+
+		if len(planet.docked_ships) == 0 {
+			planet.owned = false
+			planet.owner = -1
+		}
+	}
+}
+
+func (self *TurnSim) process_production() {
+
+	for _, planet := range self.planets {
+
+		if planet.owned == false || planet.exploded {
+			continue
+		}
+
+		docked := 0
+		for _, ship := range planet.docked_ships {
+			if ship.dockedstatus == hal.DOCKED {
+				docked++
+			}
+		}
+
+		planet.production += docked * hal.BASE_PRODUCTIVITY
+
+		if planet.production < hal.PRODUCTION_PER_SHIP {
+			continue
+		}
+
+		x, y, ok := self.spawn_point(planet)
+
+		if ok == false {				// Production is kept until there's space.
+			continue
+		}
+
+		planet.production -= hal.PRODUCTION_PER_SHIP
+
+		self.ships = append(self.ships, &TurnShip{
+			SimShip: SimShip{
+				SimEntity: SimEntity{
+					x: x,
+					y: y,
+					radius: hal.SHIP_RADIUS,
+				},
+				ship_state: ALIVE,
+				weapon_state: READY,
+				dockedstatus: hal.UNDOCKED,
+				owner: planet.owner,
+				hp: hal.MAX_SHIP_HEALTH,
+				id: self.next_ship_id,
+			},
+			docked_planet: -1,
+		})
+
+		self.next_ship_id++
+	}
+}
+
+func (self *TurnSim) spawn_point(planet *TurnPlanet) (float64, float64, bool) {
+	return hal.SpawnPoint(self.width, self.height, planet.x, planet.y, planet.radius, self.point_is_clear)
+}
+
+func (self *TurnSim) point_is_clear(x, y float64) bool {
+
+	for _, ship := range self.ships {
+		if ship.ship_state == ALIVE && hal.Dist(x, y, ship.x, ship.y) <= hal.SPAWN_CLEAR_SHIPS {
+			return false
+		}
+	}
+
+	for _, planet := range self.planets {
+		if planet.exploded == false && hal.Dist(x, y, planet.x, planet.y) <= planet.radius + hal.SPAWN_CLEAR_PLANETS {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (self *TurnSim) process_orders(commands map[int]string) {
+
+	ship_lookup := make(map[int]*TurnShip)
+	for _, ship := range self.ships {
+		ship_lookup[ship.id] = ship
+		ship.vel_x = 0
+		ship.vel_y = 0
+	}
+
+	planet_lookup := make(map[int]*TurnPlanet)
+	for _, planet := range self.planets {
+		planet_lookup[planet.id] = planet
+	}
+
+	has_order := make(map[int]bool)
+	dock_requests := make(map[int][]*TurnShip)		// Planet ID --> ships wanting to dock there
+
+	// Go through the players in order so that docking is deterministic...
+
+	var pids []int
+	for pid, _ := range commands {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+
+	for _, pid := range pids {
+
+		tokens := strings.Fields(commands[pid])
+
+		for i := 0; i < len(tokens); i++ {
+
+			var args []int
+
+			switch tokens[i] {
+			case "t": args = read_ints(tokens, i + 1, 3)
+			case "d": args = read_ints(tokens, i + 1, 2)
+			case "u": args = read_ints(tokens, i + 1, 1)
+			default: continue
+			}
+
+			if args == nil {
+				continue
+			}
+
+			order_type := tokens[i]
+			i += len(args)
+
+			ship, ok := ship_lookup[args[0]]
+
+			if ok == false || ship.owner != pid || has_order[ship.id] {
+				continue
+			}
+
+			has_order[ship.id] = true
+
+			switch order_type {
+
+			case "t":
+
+				if ship.dockedstatus != hal.UNDOCKED {
+					continue
+				}
+
+				speed := hal.Max(0, hal.Min(args[1], hal.MAX_SPEED))
+				ship.vel_x, ship.vel_y = hal.Projection(0, 0, float64(speed), args[2])		// Note: message multiples of 360 are left in the angle
+
+			case "d":
+
+				planet, ok := planet_lookup[args[1]]
+
+				if ok == false || ship.dockedstatus != hal.UNDOCKED {
+					continue
+				}
+
+				if hal.Dist(ship.x, ship.y, planet.x, planet.y) > planet.radius + hal.DOCKING_RADIUS + hal.SHIP_RADIUS {
+					continue
+				}
+
+				if planet.owned && planet.owner != pid {
+					continue
+				}
+
+				dock_requests[planet.id] = append(dock_requests[planet.id], ship)
+
+			case "u":
+
+				if ship.dockedstatus != hal.DOCKED {
+					continue
+				}
+
+				ship.dockedstatus = hal.UNDOCKING
+				ship.docking_progress = hal.DOCK_TURNS
+			}
+		}
+	}
+
+	for _, planet := range self.planets {
+
+		requests := dock_requests[planet.id]
+
+		if len(requests) == 0 {
+			continue
+		}
+
+		// Multiple players trying to dock at an unowned planet cancel each other out...
+
+		if planet.owned == false {
+			contested := false
+			for _, ship := range requests {
+				if ship.owner != requests[0].owner {
+					contested = true
+				}
+			}
+			if contested {
+				continue
+			}
+		}
+
+		for _, ship := range requests {
+
+			if len(planet.docked_ships) >= planet.docking_spots {
+				break
+			}
+
+			ship.dockedstatus = hal.DOCKING
+			ship.docking_progress = hal.DOCK_TURNS
+			ship.docked_planet = planet.id
+
+			if planet.owned == false {		// An unowned planet keeps its production until someone claims it, even its old owner.
+				planet.production = 0
+			}
+
+			planet.docked_ships = append(planet.docked_ships, ship)
+			planet.owned = true
+			planet.owner = ship.owner
+		}
+	}
+}
+
+func read_ints(tokens []string, start, count int) []int {
+
+	if start + count > len(tokens) {
+		return nil
+	}
+
+	var ret []int
+
+	for _, token := range tokens[start:start + count] {
+		n, err := strconv.Atoi(token)
+		if err != nil {
+			return nil
+		}
+		ret = append(ret, n)
+	}
+
+	return ret
+}
+
+func (self *TurnSim) process_events() {
+
+	// This follows the logic of Sim.Step() but with weapon cooldown, planet damage and explosions.
+
+	var possible_events []*TurnEvent
+
+	for _, ship := range self.ships {
+		ship.actual_targets = nil
+		ship.weapon_state = READY
+	}
+
+	for i, ship_a := range self.ships {
+
+		if ship_a.ship_state == DEAD {
+			continue
+		}
+
+		for _, ship_b := range self.ships[i+1:] {
+
+			if ship_b.ship_state == DEAD {
+				continue
+			}
+
+			if ship_a.owner != ship_b.owner && (ship_a.can_fire() || ship_b.can_fire()) {
+				t, ok := CollisionTime(hal.WEAPON_RANGE + hal.SHIP_RADIUS * 2, &ship_a.SimEntity, &ship_b.SimEntity)
+				if ok && t >= 0 && t <= 1 {
+					possible_events = append(possible_events, &TurnEvent{ship_a, ship_b, nil, t, ATTACK})
+				}
+			}
+
+			t, ok := CollisionTime(hal.SHIP_RADIUS * 2, &ship_a.SimEntity, &ship_b.SimEntity)
+			if ok && t >= 0 && t <= 1 {
+				possible_events = append(possible_events, &TurnEvent{ship_a, ship_b, nil, t, SHIP_COLLISION})
+			}
+		}
+	}
+
+	for _, planet := range self.planets {
+		for _, ship := range self.ships {
+			if ship.ship_state == DEAD {
+				continue
+			}
+			t, ok := CollisionTime(planet.radius + hal.SHIP_RADIUS, &ship.SimEntity, &planet.SimEntity)
+			if ok && t >= 0 && t <= 1 {
+				possible_events = append(possible_events, &TurnEvent{ship, nil, planet, t, PLANET_COLLISION})
+			}
+		}
+	}
+
+	// The engine rounds event times to 4 decimal places, so events that nearly coincide happen together...
+
+	for _, event := range possible_events {
+		event.t = math.Round(event.t * 10000) / 10000
+	}
+
+	sort.SliceStable(possible_events, func(a, b int) bool {
+		return possible_events[a].t < possible_events[b].t
+	})
+
+	var grouped_events [][]*TurnEvent
+
+	current_t := -1.0
+
+	for _, event := range possible_events {
+		if event.t > current_t {
+			current_t = event.t
+			grouped_events = append(grouped_events, nil)
+		}
+		grouped_events[len(grouped_events) - 1] = append(grouped_events[len(grouped_events) - 1], event)
+	}
+
+	for _, grouping := range grouped_events {
+
+		for _, event := range grouping {
+
+			switch event.what {
+
+			case SHIP_COLLISION:
+
+				ship_a, ship_b := event.ship_a, event.ship_b
+
+				if ship_a.ship_state == DEAD || ship_b.ship_state == DEAD {
+					continue
+				}
+
+				ship_a.hp = 0
+				ship_b.hp = 0
+
+			case ATTACK:
+
+				ship_a, ship_b := event.ship_a, event.ship_b
+
+				if ship_a.ship_state == DEAD || ship_b.ship_state == DEAD {
+					continue
+				}
+
+				if ship_a.weapon_state != SPENT && ship_a.can_fire() {
+					ship_a.weapon_state = FIRING
+					ship_a.actual_targets = append(ship_a.actual_targets, &ship_b.SimShip)
+				}
+
+				if ship_b.weapon_state != SPENT && ship_b.can_fire() {
+					ship_b.weapon_state = FIRING
+					ship_b.actual_targets = append(ship_b.actual_targets, &ship_a.SimShip)
+				}
+
+			case PLANET_COLLISION:
+
+				ship, planet := event.ship_a, event.planet
+
+				if ship.ship_state == DEAD || planet.exploded {
+					continue
+				}
+
+				planet.hp -= hal.Max(0, ship.hp)		// The planet takes the ship's remaining HP as damage.
+				ship.hp = 0
+			}
+		}
+
+		// Apply weapon damage. The engine adds up the fractional damage each ship takes
+		// at this moment, and only then truncates it...
+
+		incoming := make(map[*SimShip]float64)
+
+		for _, ship := range self.ships {
+			if len(ship.actual_targets) > 0 {
+				damage := float64(hal.WEAPON_DAMAGE) / float64(len(ship.actual_targets))
+				for _, target := range ship.actual_targets {
+					incoming[target] += damage
+					self.damage_dealt[ship.owner] += hal.WEAPON_DAMAGE / len(ship.actual_targets)
+				}
+			}
+		}
+
+		for _, ship := range self.ships {
+			if damage, ok := incoming[&ship.SimShip]; ok {
+				ship.hp -= int(damage)
+			}
+		}
+
+		for _, ship := range self.ships {
+
+			if ship.weapon_state == FIRING {
+				ship.weapon_state = SPENT
+				ship.cooldown = hal.WEAPON_COOLDOWN
+			}
+
+			ship.actual_targets = nil
+		}
+
+		self.resolve_deaths()
+	}
+}
+
+func (self *TurnSim) resolve_deaths() {
+
+	// Explosions can destroy planets, so keep going until nothing new happens.
+
+	for {
+
+		for _, ship := range self.ships {
+			if ship.ship_state == ALIVE && ship.hp <= 0 {
+				self.kill_ship(ship)
+			}
+		}
+
+		var exploding []*TurnPlanet
+
+		for _, planet := range self.planets {
+			if planet.exploded == false && planet.hp <= 0 {
+				exploding = append(exploding, planet)
+			}
+		}
+
+		if len(exploding) == 0 {
+			return
+		}
+
+		for _, planet := range exploding {
+			self.explode(planet)
+		}
+	}
+}
+
+func (self *TurnSim) kill_ship(ship *TurnShip) {
+
+	ship.hp = 0
+	ship.ship_state = DEAD
+
+	for _, planet := range self.planets {
+		for i, docked := range planet.docked_ships {
+			if docked == ship {
+				planet.docked_ships = append(planet.docked_ships[:i], planet.docked_ships[i+1:]...)
+				if len(planet.docked_ships) == 0 {
+					planet.owned = false
+					planet.owner = -1
+				}
+				break
+			}
+		}
+	}
+}
+
+func (self *TurnSim) explode(planet *TurnPlanet) {
+
+	// Docked ships die, and everything else nearby takes damage which falls off with distance from the planet's surface.
+
+	planet.exploded = true
+	planet.hp = 0
+
+	for _, ship := range planet.docked_ships {
+		ship.hp = 0
+	}
+
+	for _, ship := range self.ships {
+
+		if ship.ship_state == DEAD {
+			continue
+		}
+
+		surface_dist := hal.MaxFloat(0, hal.Dist(ship.x, ship.y, planet.x, planet.y) - planet.radius)
+
+		if surface_dist < hal.EXPLOSION_RADIUS {
+			ship.hp -= explosion_damage(surface_dist)
+		}
+	}
+
+	for _, other := range self.planets {
+
+		if other == planet || other.exploded {
+			continue
+		}
+
+		surface_dist := hal.MaxFloat(0, hal.Dist(other.x, other.y, planet.x, planet.y) - planet.radius - other.radius)
+
+		if surface_dist < hal.EXPLOSION_RADIUS {
+			other.hp -= explosion_damage(surface_dist)
+		}
+	}
+}
+
+func explosion_damage(surface_dist float64) int {
+	return int(math.Ceil(hal.MAX_SHIP_HEALTH * (1 - surface_dist / hal.EXPLOSION_RADIUS)))
+}
+
+func (self *TurnSim) process_movement() {
+
+	for _, ship := range self.ships {
+
+		if ship.ship_state == DEAD {
+			continue
+		}
+
+		ship.x += ship.vel_x
+		ship.y += ship.vel_y
+
+		ship.vel_x = 0
+		ship.vel_y = 0
+
+		if ship.x < 0 || ship.x >= float64(self.width) || ship.y < 0 || ship.y >= float64(self.height) {
+			self.kill_ship(ship)
+		}
+	}
+}
+
+func (self *TurnSim) process_cooldowns() {
+	for _, ship := range self.ships {
+		if ship.cooldown > 0 {
+			ship.cooldown--
+		}
+	}
+}
+
+func (self *TurnSim) remove_dead() {
+
+	var live_ships []*TurnShip
+	for _, ship := range self.ships {
+		if ship.ship_state == ALIVE {
+			live_ships = append(live_ships, ship)
+		}
+	}
+	self.ships = live_ships
+
+	var live_planets []*TurnPlanet
+	for _, planet := range self.planets {
+		if planet.exploded == false {
+			live_planets = append(live_planets, planet)
+		}
+	}
+	self.planets = live_planets
+}
+
+func (self *TurnShip) can_fire() bool {
+	return self.dockedstatus == hal.UNDOCKED && self.cooldown == 0
+}
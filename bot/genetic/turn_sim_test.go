@@ -0,0 +1,251 @@
+package genetic
+
+import (
+	"fmt"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	hal "../core"
+	rep "../replay"
+)
+
+// Steps a TurnSim through reference replays, one turn at a time, with every player's real commands, and checks
+// the result against the replay's next frame: which ships exist, and their owner, position, HP and docking; and
+// which planets exist, and their HP, owner, docked ships and production.
+//
+// The replays are ones where the sim matches the engine exactly. In some others, the engine breaks a tie between
+// two spawn points (or rounds a sum of split weapon damage) on floating point noise that we can't reproduce. None of
+// the reference replays has a planet being destroyed, so explosions are only tested as far as ship collisions go.
+
+const (
+	TURN_SIM_POSITION_TOLERANCE = 0.0001
+)
+
+var turn_sim_test_replays = []string{
+	"v54 - game v shummie.hlt",					// 2 players, lots of production
+	"v64 - losing but winning.hlt",				// 4 players, long, undocking
+	"v76 - lol 4 player rush meta 2.hlt",		// 4 players, rushing
+	"v82 - out of space.hlt",					// Undocking to fight
+	"v89 - avoid the edge.hlt",					// Ships near the map edge
+	"v97 - spite.hlt",							// Players timing out; planets changing hands
+}
+
+var turn_sim_test_statuses = map[string]hal.DockedStatus{
+	"undocked": hal.UNDOCKED,
+	"docking": hal.DOCKING,
+	"docked": hal.DOCKED,
+	"undocking": hal.UNDOCKING,
+}
+
+func TestTurnSimAgainstReplays(t *testing.T) {
+
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd is not installed, so the reference replays can't be read")
+	}
+
+	for _, name := range turn_sim_test_replays {
+
+		name := name
+
+		t.Run(name, func(t *testing.T) {
+
+			replay, err := rep.Load(filepath.Join("..", "..", "reference replays", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			playback := replay.NewPlayback(0)
+
+			for playback.Next() && playback.Turn() < replay.Turns() {
+
+				turn := playback.Turn()
+
+				sim := NewTurnSim(playback.Game())
+
+				for _, pid := range timed_out(replay, turn) {
+					sim.RemovePlayer(pid)
+				}
+
+				commands := make(map[int]string)
+				for pid := 0; pid < replay.NumPlayers; pid++ {
+					commands[pid] = player_commands(replay, turn, pid)
+				}
+
+				sim.Step(commands)
+
+				for _, problem := range compare_with_frame(sim, replay.Frames[turn + 1]) {
+					t.Errorf("turn %d: %s", turn, problem)
+				}
+			}
+		})
+	}
+}
+
+func player_commands(replay *rep.Replay, turn, pid int) string {
+
+	// Like replay.Commands(), except when more of the player's ships asked to dock at a planet than it had
+	// free spots. Which ones the engine took depended on the order the commands were sent in, which the
+	// replay doesn't keep, so the losers' dock commands (which did nothing) are left out.
+
+	next := replay.Frames[turn + 1].Ships[pid]
+	moves := replay.PlayerMoves(turn, pid)
+
+	docked_at := func(sid, planet_id int) bool {
+		ship, ok := next[sid]
+		return ok && ship.Docking.PlanetId != nil && *ship.Docking.PlanetId == planet_id
+	}
+
+	var parts []string
+
+	for _, move := range moves {
+
+		if move.Type == "dock" && next[move.ShipId] != nil && docked_at(move.ShipId, move.PlanetId) == false {
+
+			contested := false
+			for _, other := range moves {
+				if other.Type == "dock" && other.PlanetId == move.PlanetId && docked_at(other.ShipId, other.PlanetId) {
+					contested = true
+				}
+			}
+
+			if contested {
+				continue
+			}
+		}
+
+		parts = append(parts, move.String())
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func timed_out(replay *rep.Replay, turn int) []int {
+
+	// Players the engine removed before processing the turn. All their ships vanish,
+	// without the destroyed events that ships dying during the turn would have.
+
+	var ret []int
+
+	destroyed := make(map[int]bool)
+	for _, event := range replay.Frames[turn].Events {
+		if event.Event == "destroyed" && event.Entity.Type == "ship" {
+			destroyed[event.Entity.Owner] = true
+		}
+	}
+
+	for pid := 0; pid < replay.NumPlayers; pid++ {
+		if len(replay.Frames[turn].Ships[pid]) > 0 && len(replay.Frames[turn + 1].Ships[pid]) == 0 && destroyed[pid] == false {
+			ret = append(ret, pid)
+		}
+	}
+
+	return ret
+}
+
+func compare_with_frame(sim *TurnSim, frame *rep.Frame) []string {
+
+	var problems []string
+
+	add := func(format_string string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format_string, args...))
+	}
+
+	expected := make(map[int]*rep.FrameShip)
+	for _, player_ships := range frame.Ships {
+		for sid, ship := range player_ships {
+			expected[sid] = ship
+		}
+	}
+
+	seen := make(map[int]bool)
+
+	for _, ship := range sim.Ships() {
+
+		seen[ship.Id] = true
+		want, ok := expected[ship.Id]
+
+		if ok == false {
+			add("ship %d exists, but shouldn't", ship.Id)
+			continue
+		}
+
+		want_planet := -1
+		if want.Docking.PlanetId != nil {
+			want_planet = *want.Docking.PlanetId
+		}
+
+		if ship.Owner != want.Owner {
+			add("ship %d: owner %d, should be %d", ship.Id, ship.Owner, want.Owner)
+		}
+		if math.Abs(ship.X - want.X) > TURN_SIM_POSITION_TOLERANCE || math.Abs(ship.Y - want.Y) > TURN_SIM_POSITION_TOLERANCE {
+			add("ship %d: at %.4f %.4f, should be %.4f %.4f", ship.Id, ship.X, ship.Y, want.X, want.Y)
+		}
+		if ship.HP != want.Health {
+			add("ship %d: HP %d, should be %d", ship.Id, ship.HP, want.Health)
+		}
+		if ship.DockedStatus != turn_sim_test_statuses[want.Docking.Status] || ship.DockedPlanet != want_planet {
+			add("ship %d: docking %v at %d, should be %s at %d", ship.Id, ship.DockedStatus, ship.DockedPlanet, want.Docking.Status, want_planet)
+		} else if ship.DockingProgress != want.Docking.TurnsLeft {
+			add("ship %d: %d docking turns left, should be %d", ship.Id, ship.DockingProgress, want.Docking.TurnsLeft)
+		}
+	}
+
+	var missing []int
+	for sid := range expected {
+		if seen[sid] == false {
+			missing = append(missing, sid)
+		}
+	}
+	sort.Ints(missing)
+	for _, sid := range missing {
+		add("ship %d is missing", sid)
+	}
+
+	planets_seen := make(map[int]bool)
+
+	for _, planet := range sim.Planets() {
+
+		planets_seen[planet.Id] = true
+		want, ok := frame.Planets[planet.Id]
+
+		if ok == false {
+			add("planet %d exists, but shouldn't", planet.Id)
+			continue
+		}
+
+		owner := -1
+		if want.Owner != nil {
+			owner = *want.Owner
+		}
+
+		if planet.HP != want.Health {
+			add("planet %d: HP %d, should be %d", planet.Id, planet.HP, want.Health)
+		}
+		if planet.Owner != owner {
+			add("planet %d: owner %d, should be %d", planet.Id, planet.Owner, owner)
+		}
+		if planet.DockedShips != len(want.DockedShips) {
+			add("planet %d: %d docked ships, should be %d", planet.Id, planet.DockedShips, len(want.DockedShips))
+		}
+		if planet.CurrentProduction != want.CurrentProduction {
+			add("planet %d: production %d, should be %d", planet.Id, planet.CurrentProduction, want.CurrentProduction)
+		}
+	}
+
+	var planets_missing []int
+	for pid := range frame.Planets {
+		if planets_seen[pid] == false {
+			planets_missing = append(planets_missing, pid)
+		}
+	}
+	sort.Ints(planets_missing)
+	for _, pid := range planets_missing {
+		add("planet %d is missing", pid)
+	}
+
+	return problems
+}
@@ -0,0 +1,205 @@
+package local
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	hal "../core"
+)
+
+// A simple imitation of the official "SolarSystem" map generator. Maps are 3:2, players
+// start with 3 ships in a vertical line, there are 4 small planets at the centre (IDs 0-3,
+// which the bot relies on) and the other planets come in mirrored groups of 4.
+
+var map_widths = []int{240, 264, 288, 312, 336, 360, 384}
+
+type MapPlanet struct {
+	Id				int
+	X				float64
+	Y				float64
+	Radius			float64
+}
+
+type MapShip struct {
+	Id				int
+	Owner			int
+	X				float64
+	Y				float64
+}
+
+type Map struct {
+	Width			int
+	Height			int
+	Players			int
+	Planets			[]*MapPlanet
+	Ships			[]*MapShip
+}
+
+func GenerateMap(seed int64, players int, width int) *Map {
+
+	if players != 2 && players != 4 {
+		panic(fmt.Sprintf("GenerateMap(): can't make a map for %d players", players))
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+
+	if width <= 0 {
+		width = map_widths[rng.Intn(len(map_widths))]
+	}
+
+	m := &Map{
+		Width: width,
+		Height: width * 2 / 3,
+		Players: players,
+	}
+
+	cx, cy := float64(m.Width) / 2, float64(m.Height) / 2
+
+	// Ships...
+
+	var spawns []*hal.Point
+
+	if players == 2 {
+		spawns = []*hal.Point{
+			&hal.Point{X: cx, Y: float64(m.Height) / 4},
+			&hal.Point{X: cx, Y: float64(m.Height) * 3 / 4},
+		}
+	} else {
+		spawns = []*hal.Point{
+			&hal.Point{X: float64(m.Width) / 4, Y: float64(m.Height) / 4},
+			&hal.Point{X: float64(m.Width) * 3 / 4, Y: float64(m.Height) / 4},
+			&hal.Point{X: float64(m.Width) / 4, Y: float64(m.Height) * 3 / 4},
+			&hal.Point{X: float64(m.Width) * 3 / 4, Y: float64(m.Height) * 3 / 4},
+		}
+	}
+
+	for pid, spawn := range spawns {
+		for n, dy := range []float64{0, -3, 3} {
+			m.Ships = append(m.Ships, &MapShip{pid * 3 + n, pid, spawn.X, spawn.Y + dy})
+		}
+	}
+
+	// Centre planets...
+
+	centre_radius := 4 + rng.Float64() * 2
+	offset := centre_radius * 1.55
+
+	m.add_planet(cx + offset, cy + offset, centre_radius)
+	m.add_planet(cx - offset, cy + offset, centre_radius)
+	m.add_planet(cx - offset, cy - offset, centre_radius)
+	m.add_planet(cx + offset, cy - offset, centre_radius)
+
+	// Other planets, in groups of 4, placed in the top-left quarter and mirrored...
+
+	groups_wanted := players + 1
+
+	for attempt := 0; attempt < 2000 && groups_wanted > 0; attempt++ {
+
+		radius := 4 + rng.Float64() * rng.Float64() * 9.5
+		x := radius + 3 + rng.Float64() * (cx - radius * 2 - 6)
+		y := radius + 3 + rng.Float64() * (cy - radius * 2 - 6)
+
+		if m.can_place(x, y, radius, spawns) == false {
+			continue
+		}
+
+		m.add_planet(x, y, radius)
+		m.add_planet(float64(m.Width) - x, float64(m.Height) - y, radius)
+		m.add_planet(float64(m.Width) - x, y, radius)
+		m.add_planet(x, float64(m.Height) - y, radius)
+
+		groups_wanted--
+	}
+
+	return m
+}
+
+func (self *Map) add_planet(x, y, radius float64) {
+	self.Planets = append(self.Planets, &MapPlanet{len(self.Planets), x, y, radius})
+}
+
+func (self *Map) can_place(x, y, radius float64, spawns []*hal.Point) bool {
+
+	const (
+		PLANET_GAP = 6.0
+		SPAWN_GAP = 12.0
+	)
+
+	mirrors := [][2]float64{
+		{x, y},
+		{float64(self.Width) - x, float64(self.Height) - y},
+		{float64(self.Width) - x, y},
+		{x, float64(self.Height) - y},
+	}
+
+	for i, a := range mirrors {
+
+		for _, b := range mirrors[i+1:] {
+			if hal.Dist(a[0], a[1], b[0], b[1]) < radius * 2 + PLANET_GAP {
+				return false
+			}
+		}
+
+		for _, planet := range self.Planets {
+			if hal.Dist(a[0], a[1], planet.X, planet.Y) < radius + planet.Radius + PLANET_GAP {
+				return false
+			}
+		}
+
+		for _, spawn := range spawns {
+			if hal.Dist(a[0], a[1], spawn.X, spawn.Y) < radius + SPAWN_GAP {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func PlanetHealth(radius float64) int {
+	return int(radius * hal.MAX_SHIP_HEALTH)
+}
+
+func PlanetDockingSpots(radius float64) int {
+	return hal.Max(2, int(math.Floor(radius / 3)) + 1)
+}
+
+func (self *Map) WorldString() string {
+
+	// The initial world, as the engine would send it.
+
+	var tokens []string
+
+	add := func(format_string string, args ...interface{}) {
+		tokens = append(tokens, fmt.Sprintf(format_string, args...))
+	}
+
+	add("%d", self.Players)
+
+	for pid := 0; pid < self.Players; pid++ {
+
+		var player_ships []*MapShip
+		for _, ship := range self.Ships {
+			if ship.Owner == pid {
+				player_ships = append(player_ships, ship)
+			}
+		}
+
+		add("%d %d", pid, len(player_ships))
+
+		for _, ship := range player_ships {
+			add("%d %f %f %d 0.0 0.0 %d 0 0 0", ship.Id, ship.X, ship.Y, hal.MAX_SHIP_HEALTH, hal.UNDOCKED)
+		}
+	}
+
+	add("%d", len(self.Planets))
+
+	for _, planet := range self.Planets {
+		add("%d %f %f %d %f %d 0 0 0 0 0",
+			planet.Id, planet.X, planet.Y, PlanetHealth(planet.Radius), planet.Radius, PlanetDockingSpots(planet.Radius))
+	}
+
+	return strings.Join(tokens, " ")
+}
@@ -0,0 +1,275 @@
+package local
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	hal "../core"
+	gen "../genetic"
+	rep "../replay"
+)
+
+const (
+	MAX_TURNS = 300
+	INIT_TIMEOUT = 30 * time.Second
+	TURN_TIMEOUT = 2 * time.Second
+)
+
+type Match struct {
+	Players			[]Player
+	Seed			int64
+	Width			int				// 0 means random
+	MaxTurns		int
+	TurnTimeout		time.Duration	// 0 means none
+	ReplayFile		string			// "" means no replay
+
+	// Called after every turn, if set (e.g. for watching the game)...
+
+	OnTurn			func(sim *gen.TurnSim, result *Result)
+}
+
+type PlayerResult struct {
+	Pid					int
+	Name				string
+	Rank				int
+	LastFrameAlive		int
+	TotalShips			int				// Cumulative, i.e. the tiebreaker
+	DamageDealt			int
+	Error				error
+	TotalTime			time.Duration
+	LongestTurn			time.Duration
+	LastTurnTime		time.Duration	// These two are for OnTurn...
+	LastCommands		string
+}
+
+type Result struct {
+	Turns				int
+	Players				[]*PlayerResult
+}
+
+func (self *Result) Winner() *PlayerResult {
+	for _, player := range self.Players {
+		if player.Rank == 1 {
+			return player
+		}
+	}
+	return nil
+}
+
+func (self *Result) String() string {
+	s := fmt.Sprintf("Turns: %d\n", self.Turns)
+	for _, player := range self.Players {
+		s += fmt.Sprintf("  #%d  pid %d  %-30s  ships %3d  damage %5d  longest %v",
+			player.Rank, player.Pid, player.Name, player.TotalShips, player.DamageDealt, player.LongestTurn.Truncate(time.Millisecond))
+		if player.Error != nil {
+			s += fmt.Sprintf("  (%v)", player.Error)
+		}
+		s += "\n"
+	}
+	return s
+}
+
+func (self *Match) Run() *Result {
+
+	players := len(self.Players)
+
+	max_turns := self.MaxTurns
+	if max_turns <= 0 {
+		max_turns = MAX_TURNS
+	}
+
+	m := GenerateMap(self.Seed, players, self.Width)
+
+	initial_world := m.WorldString()
+	sim := gen.NewTurnSim(hal.NewGameFromString(fmt.Sprintf("0 %d %d %s", m.Width, m.Height, initial_world), -1))
+
+	result := new(Result)
+	alive := make([]bool, players)
+	seen_ships := make(map[int]bool)
+
+	for pid := 0; pid < players; pid++ {
+		result.Players = append(result.Players, &PlayerResult{Pid: pid})
+		alive[pid] = true
+	}
+
+	for _, ship := range sim.Ships() {
+		seen_ships[ship.Id] = true
+		result.Players[ship.Owner].TotalShips++
+	}
+
+	defer func() {
+		for _, player := range self.Players {
+			player.Close()
+		}
+	}()
+
+	// Init...
+
+	self.all_players(alive, func(pid int) {
+		init_message := fmt.Sprintf("%d\n%d %d\n%s", pid, m.Width, m.Height, initial_world)
+		name, err := self.Players[pid].Init(init_message, INIT_TIMEOUT)
+		result.Players[pid].Name = name
+		if err != nil {
+			result.Players[pid].Error = err
+		}
+	})
+
+	var names []string
+	for pid := 0; pid < players; pid++ {
+		if result.Players[pid].Error != nil {
+			alive[pid] = false
+			sim.RemovePlayer(pid)
+		}
+		names = append(names, result.Players[pid].Name)
+	}
+
+	replay := NewReplayWriter(m, self.Seed, names)
+	replay.AddFrame(sim)
+
+	// Main loop...
+
+	for turn := 0; turn < max_turns; turn++ {
+
+		if count_true(alive) < 2 && players > 1 {
+			break
+		}
+
+		world := sim.WorldString()
+
+		var commands = make(map[int]string)
+		var lock sync.Mutex
+
+		self.all_players(alive, func(pid int) {
+
+			start_time := time.Now()
+			s, err := self.Players[pid].Turn(world, self.TurnTimeout)
+			elapsed := time.Now().Sub(start_time)
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			result.Players[pid].TotalTime += elapsed
+			result.Players[pid].LastTurnTime = elapsed
+			if elapsed > result.Players[pid].LongestTurn {
+				result.Players[pid].LongestTurn = elapsed
+			}
+
+			if err != nil {
+				result.Players[pid].Error = fmt.Errorf("turn %d: %v", turn, err)
+				return
+			}
+
+			commands[pid] = s
+			result.Players[pid].LastCommands = s
+		})
+
+		for pid := 0; pid < players; pid++ {
+			if alive[pid] && result.Players[pid].Error != nil {
+				sim.RemovePlayer(pid)
+			}
+		}
+
+		sim.Step(commands)
+
+		replay.AddMoves(commands)
+		replay.AddFrame(sim)
+
+		result.Turns = turn + 1
+
+		for _, ship := range sim.Ships() {
+			if seen_ships[ship.Id] == false {
+				seen_ships[ship.Id] = true
+				result.Players[ship.Owner].TotalShips++
+			}
+		}
+
+		for pid := 0; pid < players; pid++ {
+			if alive[pid] {
+				if sim.ShipCount(pid) == 0 {
+					alive[pid] = false
+				} else {
+					result.Players[pid].LastFrameAlive = turn + 1
+				}
+			}
+			result.Players[pid].DamageDealt = sim.DamageDealt(pid)
+		}
+
+		if self.OnTurn != nil {
+			self.OnTurn(sim, result)
+		}
+	}
+
+	set_ranks(result)
+
+	if self.ReplayFile != "" {
+		for _, player := range result.Players {
+			replay.SetStats(player.Pid, &rep.PlayerStats{
+				Rank: player.Rank,
+				LastFrameAlive: player.LastFrameAlive,
+				TotalShipCount: player.TotalShips,
+				DamageDealt: player.DamageDealt,
+				AverageFrameResponseTime: float64(player.TotalTime / time.Millisecond) / float64(hal.Max(1, result.Turns)),
+				MaxFrameResponseTime: int(player.LongestTurn / time.Millisecond),
+			})
+		}
+		err := replay.Save(self.ReplayFile)
+		if err != nil {
+			fmt.Printf("Couldn't save replay: %v\n", err)
+		}
+	}
+
+	return result
+}
+
+func (self *Match) all_players(alive []bool, f func(pid int)) {
+
+	// Run f for every live player concurrently. In-process players serialise themselves.
+
+	var wg sync.WaitGroup
+
+	for pid := 0; pid < len(self.Players); pid++ {
+		if alive[pid] {
+			wg.Add(1)
+			go func(pid int) {
+				defer wg.Done()
+				f(pid)
+			}(pid)
+		}
+	}
+
+	wg.Wait()
+}
+
+func set_ranks(result *Result) {
+
+	// Survival matters most, then total ships produced, then damage dealt.
+
+	ranked := make([]*PlayerResult, len(result.Players))
+	copy(ranked, result.Players)
+
+	sort.SliceStable(ranked, func(a, b int) bool {
+		if ranked[a].LastFrameAlive != ranked[b].LastFrameAlive {
+			return ranked[a].LastFrameAlive > ranked[b].LastFrameAlive
+		}
+		if ranked[a].TotalShips != ranked[b].TotalShips {
+			return ranked[a].TotalShips > ranked[b].TotalShips
+		}
+		return ranked[a].DamageDealt > ranked[b].DamageDealt
+	})
+
+	for i, player := range ranked {
+		player.Rank = i + 1
+	}
+}
+
+func count_true(bools []bool) int {
+	count := 0
+	for _, b := range bools {
+		if b {
+			count++
+		}
+	}
+	return count
+}
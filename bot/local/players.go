@@ -0,0 +1,244 @@
+package local
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	ai "../ai"
+	hal "../core"
+)
+
+// A Player is anything that speaks the Halite II protocol. Init() is given the engine's
+// initial message (pid, map size, world) and returns the bot's name; Turn() is given the
+// world and returns the bot's commands.
+
+type Player interface {
+	Init(init_message string, timeout time.Duration) (string, error)
+	Turn(world string, timeout time.Duration) (string, error)
+	Close()
+}
+
+func NewPlayer(arg string, dir string) (Player, error) {
+
+	// "builtin" followed by the usual bot flags runs an Overmind in this very process; anything else is
+	// a command line, run in dir. Each builtin player parses its own Config, so they needn't agree.
+
+	fields := strings.Fields(arg)
+
+	if len(fields) > 0 && fields[0] == "builtin" {
+		config := new(ai.Config)
+		fs := flag.NewFlagSet("builtin", flag.ContinueOnError)
+		config.RegisterFlags(fs)
+		err := fs.Parse(fields[1:])
+		if err != nil {
+			return nil, err
+		}
+		return NewInProcessPlayer(config), nil
+	}
+
+	return NewSubprocessPlayer(arg, dir), nil
+}
+
+// ---------------------------------------------------------------------------------------
+
+type SubprocessPlayer struct {
+	command			string
+	dir				string
+	cmd				*exec.Cmd
+	stdin			io.WriteCloser
+	lines			chan string
+	read_err		chan error
+	done			chan bool				// Closed by Close(), so the reader goroutine can't be left blocked
+}
+
+func NewSubprocessPlayer(command, dir string) *SubprocessPlayer {
+	return &SubprocessPlayer{
+		command: command,
+		dir: dir,
+	}
+}
+
+func (self *SubprocessPlayer) Init(init_message string, timeout time.Duration) (string, error) {
+
+	fields := strings.Fields(self.command)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+
+	self.cmd = exec.Command(fields[0], fields[1:]...)
+	self.cmd.Dir = self.dir
+	self.cmd.Stderr = os.Stderr
+
+	var err error
+
+	self.stdin, err = self.cmd.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+
+	stdout, err := self.cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+
+	err = self.cmd.Start()
+	if err != nil {
+		return "", err
+	}
+
+	// Read lines in the background so that we can time out. After a timeout nobody may ever want the
+	// next line, so the reader also gives up when we're closed...
+
+	lines := make(chan string)
+	read_err := make(chan error, 1)
+	done := make(chan bool)
+
+	self.lines, self.read_err, self.done = lines, read_err, done
+
+	go func() {
+		reader := bufio.NewReader(stdout)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				read_err <- err
+				return
+			}
+			select {
+			case lines <- strings.TrimRight(line, "\r\n"):
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return self.exchange(init_message, timeout)
+}
+
+func (self *SubprocessPlayer) Turn(world string, timeout time.Duration) (string, error) {
+	return self.exchange(world, timeout)
+}
+
+func (self *SubprocessPlayer) exchange(message string, timeout time.Duration) (string, error) {
+
+	// Throw away any late reply to an earlier message, so it isn't taken as the reply to this one...
+
+	drain:
+	for {
+		select {
+		case <-self.lines:
+		default:
+			break drain
+		}
+	}
+
+	_, err := fmt.Fprintf(self.stdin, "%s\n", message)
+	if err != nil {
+		return "", err
+	}
+
+	var timer <-chan time.Time
+	if timeout > 0 {
+		timer = time.After(timeout)
+	}
+
+	select {
+	case line := <-self.lines:
+		return line, nil
+	case err := <-self.read_err:
+		return "", err
+	case <-timer:
+		return "", fmt.Errorf("timed out after %v", timeout)
+	}
+}
+
+func (self *SubprocessPlayer) Close() {
+	if self.done != nil {
+		close(self.done)
+		self.done = nil
+	}
+	if self.cmd != nil && self.cmd.Process != nil {
+		self.stdin.Close()
+		self.cmd.Process.Kill()
+		self.cmd.Wait()
+	}
+}
+
+// ---------------------------------------------------------------------------------------
+
+// The InProcessPlayer runs an Overmind directly. Since the bot uses the global RNG,
+// only one of these is ever allowed to think at a time.
+
+var inprocess_mutex sync.Mutex
+
+type InProcessPlayer struct {
+	config			*ai.Config
+	transport		*hal.ChanTransport
+	game			*hal.Game
+	overmind		*ai.Overmind
+}
+
+func NewInProcessPlayer(config *ai.Config) *InProcessPlayer {
+	return &InProcessPlayer{
+		config: config,
+		transport: hal.NewChanTransport(),
+	}
+}
+
+func (self *InProcessPlayer) Init(init_message string, timeout time.Duration) (name string, err error) {
+
+	inprocess_mutex.Lock()
+	defer inprocess_mutex.Unlock()
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+
+	self.transport.Post(init_message)
+
+	self.game = hal.NewGameFromTransport(self.transport)
+	self.overmind = ai.NewOvermind(self.game, self.config)
+	self.game.SendName("Overmind (in-process)")
+
+	return self.transport.Receive(), nil
+}
+
+func (self *InProcessPlayer) Turn(world string, timeout time.Duration) (commands string, err error) {
+
+	// Timeouts can't be enforced, but the caller can still see how long we took.
+
+	inprocess_mutex.Lock()
+	defer inprocess_mutex.Unlock()
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%v", p)
+		}
+	}()
+
+	self.transport.Post(world)
+
+	self.game.Parse()
+
+	if self.config.Timeseed == false {
+		rand.Seed(int64(self.game.Turn() + self.game.Width() + self.game.Pid()))		// As MyBot.go does.
+	}
+
+	self.overmind.Step()
+	self.game.Send(self.config.NoMsg)
+
+	return self.transport.Receive(), nil
+}
+
+func (self *InProcessPlayer) Close() {
+	self.transport.Close()
+}
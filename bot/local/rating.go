@@ -0,0 +1,196 @@
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+)
+
+// TrueSkill ratings, as the official leaderboard used, so that "worth 2 or 3 mu" can be measured at home. A game
+// of n players is treated as the n(n-1)/2 two-player games implied by the ranking, each updated from the ratings
+// as they were before the game, with the results averaged over the n-1 games each player was in; this is the usual
+// cheap stand-in for the full factor graph, and ranks players the same way, if a little more slowly for 4 player
+// games. Players are listed by mu - 3 sigma, as on the official leaderboard.
+
+const (
+	TS_MU = 25.0
+	TS_SIGMA = TS_MU / 3
+	TS_BETA = TS_SIGMA / 2						// Skill difference that gives a ~76% chance of winning
+	TS_TAU = TS_SIGMA / 100						// Added to sigma before each game, so ratings can keep moving
+)
+
+type Rating struct {
+	Mu				float64					`json:"mu"`
+	Sigma			float64					`json:"sigma"`
+	Games			int						`json:"games"`
+	Wins			int						`json:"wins"`				// i.e. ranked first
+}
+
+type Leaderboard struct {
+	Ratings			map[string]*Rating		`json:"ratings"`
+	Games			int						`json:"games"`
+}
+
+func (self *Rating) Skill() float64 {
+	return self.Mu - 3 * self.Sigma
+}
+
+func NewLeaderboard() *Leaderboard {
+	return &Leaderboard{Ratings: make(map[string]*Rating)}
+}
+
+func LoadLeaderboard(filename string) (*Leaderboard, error) {
+
+	// A missing file is an empty leaderboard, so the first tournament can create it.
+
+	raw, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return NewLeaderboard(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ret := NewLeaderboard()
+	err = json.Unmarshal(raw, ret)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	if ret.Ratings == nil {
+		ret.Ratings = make(map[string]*Rating)
+	}
+
+	return ret, nil
+}
+
+func (self *Leaderboard) Save(filename string) error {
+
+	// Via a temp file, so that being killed mid-write can't lose the leaderboard.
+
+	raw, err := json.MarshalIndent(self, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(filename + ".tmp", raw, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(filename + ".tmp", filename)
+}
+
+func (self *Leaderboard) Rating(name string) *Rating {
+	ret, ok := self.Ratings[name]
+	if ok == false {
+		ret = &Rating{Mu: TS_MU, Sigma: TS_SIGMA}
+		self.Ratings[name] = ret
+	}
+	return ret
+}
+
+func (self *Leaderboard) Update(names []string, ranks []int) {
+
+	// names[i] finished in ranks[i] (1 is first). Names must be different.
+
+	n := len(names)
+	if n < 2 || len(ranks) != n {
+		return
+	}
+
+	before := make([]Rating, n)
+	for i, name := range names {
+		r := self.Rating(name)
+		r.Sigma = math.Sqrt(r.Sigma * r.Sigma + TS_TAU * TS_TAU)
+		before[i] = *r
+	}
+
+	mu_delta := make([]float64, n)
+	variance_factor := make([]float64, n)			// Sum of the (1 - ...) factors, averaged below
+
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+
+			winner, loser := i, j
+			if ranks[j] < ranks[i] {
+				winner, loser = j, i
+			}
+
+			dw, dl, fw, fl := trueskill_1v1(before[winner], before[loser])
+
+			mu_delta[winner] += dw
+			mu_delta[loser] += dl
+			variance_factor[winner] += fw
+			variance_factor[loser] += fl
+		}
+	}
+
+	for i, name := range names {
+		r := self.Rating(name)
+		r.Mu = before[i].Mu + mu_delta[i] / float64(n - 1)
+		r.Sigma = before[i].Sigma * math.Sqrt(variance_factor[i] / float64(n - 1))
+		r.Games++
+		if ranks[i] == 1 {
+			r.Wins++
+		}
+	}
+
+	self.Games++
+}
+
+func trueskill_1v1(winner, loser Rating) (mu_winner, mu_loser, var_winner, var_loser float64) {
+
+	// The changes to mu, and the factors to multiply each variance by, for one win with no draws.
+
+	w2, l2 := winner.Sigma * winner.Sigma, loser.Sigma * loser.Sigma
+	c := math.Sqrt(2 * TS_BETA * TS_BETA + w2 + l2)
+	t := (winner.Mu - loser.Mu) / c
+
+	// v is how surprising the result was; w how much it tells us. Far into the tail (a huge upset), v tends to -t.
+
+	var v float64
+	if cdf := normal_cdf(t); cdf > 1e-300 {
+		v = normal_pdf(t) / cdf
+	} else {
+		v = -t
+	}
+	w := v * (v + t)
+
+	return w2 / c * v, -l2 / c * v, 1 - w2 / (c * c) * w, 1 - l2 / (c * c) * w
+}
+
+func normal_pdf(x float64) float64 {
+	return math.Exp(-x * x / 2) / math.Sqrt(2 * math.Pi)
+}
+
+func normal_cdf(x float64) float64 {
+	return 0.5 * math.Erfc(-x / math.Sqrt2)
+}
+
+func (self *Leaderboard) Sorted() []string {
+	var ret []string
+	for name := range self.Ratings {
+		ret = append(ret, name)
+	}
+	sort.Slice(ret, func(a, b int) bool {
+		sa, sb := self.Ratings[ret[a]].Skill(), self.Ratings[ret[b]].Skill()
+		if sa != sb {
+			return sa > sb
+		}
+		return ret[a] < ret[b]
+	})
+	return ret
+}
+
+func (self *Leaderboard) String() string {
+	s := fmt.Sprintf("%d games\n  %-4s %-30s %7s %7s %7s %6s %6s\n", self.Games, "", "name", "skill", "mu", "sigma", "games", "wins")
+	for i, name := range self.Sorted() {
+		r := self.Ratings[name]
+		s += fmt.Sprintf("  #%-3d %-30s %7.2f %7.2f %7.2f %6d %6d\n", i + 1, name, r.Skill(), r.Mu, r.Sigma, r.Games, r.Wins)
+	}
+	return s
+}
@@ -0,0 +1,214 @@
+package local
+
+import (
+	"strconv"
+	"strings"
+
+	hal "../core"
+	gen "../genetic"
+	rep "../replay"
+)
+
+// Replays are written in the official engine's JSON format (uncompressed) so that the usual viewers can read them.
+
+type ReplayWriter struct {
+	replay			*rep.Replay
+	last_ships		map[int]hal.Ship
+}
+
+func NewReplayWriter(m *Map, seed int64, names []string) *ReplayWriter {
+
+	ret := &ReplayWriter{
+		replay: &rep.Replay{
+			Version: 31,
+			Width: m.Width,
+			Height: m.Height,
+			NumPlayers: m.Players,
+			PlayerNames: names,
+			Seed: seed,
+			MapGenerator: "halite-local",
+			Stats: make(map[int]*rep.PlayerStats),
+		},
+		last_ships: make(map[int]hal.Ship),
+	}
+
+	ret.replay.Constants = map[string]interface{}{
+		"BASE_PRODUCTIVITY": hal.BASE_PRODUCTIVITY,
+		"BASE_SHIP_HEALTH": hal.MAX_SHIP_HEALTH,
+		"DOCK_RADIUS": hal.DOCKING_RADIUS,
+		"DOCK_TURNS": hal.DOCK_TURNS,
+		"EXPLOSION_RADIUS": hal.EXPLOSION_RADIUS,
+		"MAX_SHIP_HEALTH": hal.MAX_SHIP_HEALTH,
+		"MAX_SPEED": hal.MAX_SPEED,
+		"PRODUCTION_PER_SHIP": hal.PRODUCTION_PER_SHIP,
+		"SHIP_RADIUS": hal.SHIP_RADIUS,
+		"SPAWN_RADIUS": hal.SPAWN_RADIUS,
+		"WEAPON_COOLDOWN": hal.WEAPON_COOLDOWN,
+		"WEAPON_DAMAGE": hal.WEAPON_DAMAGE,
+		"WEAPON_RADIUS": hal.WEAPON_RANGE,
+	}
+
+	for _, planet := range m.Planets {
+		ret.replay.Planets = append(ret.replay.Planets, &rep.PlanetInfo{
+			Id: planet.Id,
+			X: planet.X,
+			Y: planet.Y,
+			Radius: planet.Radius,
+			Health: PlanetHealth(planet.Radius),
+			DockingSpots: PlanetDockingSpots(planet.Radius),
+		})
+	}
+
+	return ret
+}
+
+func (self *ReplayWriter) Replay() *rep.Replay {
+	return self.replay
+}
+
+func (self *ReplayWriter) AddFrame(sim *gen.TurnSim) {
+
+	frame := &rep.Frame{
+		Ships: make(map[int]map[int]*rep.FrameShip),
+		Planets: make(map[int]*rep.FramePlanet),
+		Events: []*rep.Event{},
+	}
+
+	current_ships := make(map[int]hal.Ship)
+
+	for pid := 0; pid < self.replay.NumPlayers; pid++ {
+		frame.Ships[pid] = make(map[int]*rep.FrameShip)
+	}
+
+	for _, ship := range sim.Ships() {
+
+		current_ships[ship.Id] = ship
+
+		docking := rep.Docking{Status: "undocked"}
+		planet_id := ship.DockedPlanet
+
+		switch ship.DockedStatus {
+		case hal.DOCKING:
+			docking = rep.Docking{Status: "docking", PlanetId: &planet_id, TurnsLeft: ship.DockingProgress}
+		case hal.DOCKED:
+			docking = rep.Docking{Status: "docked", PlanetId: &planet_id}
+		case hal.UNDOCKING:
+			docking = rep.Docking{Status: "undocking", PlanetId: &planet_id, TurnsLeft: ship.DockingProgress}
+		}
+
+		frame.Ships[ship.Owner][ship.Id] = &rep.FrameShip{
+			Id: ship.Id,
+			Owner: ship.Owner,
+			X: ship.X,
+			Y: ship.Y,
+			Health: ship.HP,
+			Docking: docking,
+		}
+
+		if _, ok := self.last_ships[ship.Id]; ok == false && len(self.replay.Frames) > 0 {
+			frame.Events = append(frame.Events, &rep.Event{
+				Event: "spawned",
+				Entity: rep.EntityRef{Id: ship.Id, Owner: ship.Owner, Type: "ship"},
+				X: ship.X,
+				Y: ship.Y,
+			})
+		}
+	}
+
+	// Deaths are only known to have happened sometime during the turn...
+
+	for sid, ship := range self.last_ships {
+		if _, ok := current_ships[sid]; ok == false {
+			frame.Events = append(frame.Events, &rep.Event{
+				Event: "destroyed",
+				Entity: rep.EntityRef{Id: ship.Id, Owner: ship.Owner, Type: "ship"},
+				Radius: hal.SHIP_RADIUS,
+				X: ship.X,
+				Y: ship.Y,
+			})
+		}
+	}
+
+	for _, planet := range sim.Planets() {
+
+		var owner *int
+		if planet.Owned {
+			o := planet.Owner
+			owner = &o
+		}
+
+		docked_ships := []int{}
+		for _, ship := range sim.Ships() {
+			if ship.DockedStatus != hal.UNDOCKED && ship.DockedPlanet == planet.Id {
+				docked_ships = append(docked_ships, ship.Id)
+			}
+		}
+
+		frame.Planets[planet.Id] = &rep.FramePlanet{
+			Id: planet.Id,
+			Health: planet.HP,
+			Owner: owner,
+			DockedShips: docked_ships,
+			CurrentProduction: planet.CurrentProduction,
+		}
+	}
+
+	self.replay.Frames = append(self.replay.Frames, frame)
+	self.replay.NumFrames = len(self.replay.Frames)
+	self.last_ships = current_ships
+}
+
+func (self *ReplayWriter) AddMoves(commands map[int]string) {
+
+	moves := make(map[int][]map[int]*rep.Move)
+
+	for pid := 0; pid < self.replay.NumPlayers; pid++ {
+
+		player_moves := make(map[int]*rep.Move)
+		tokens := strings.Fields(commands[pid])
+
+		for i := 0; i < len(tokens); i++ {
+
+			var move *rep.Move
+
+			switch {
+
+			case tokens[i] == "t" && i + 3 < len(tokens):
+				sid, _ := strconv.Atoi(tokens[i + 1])
+				magnitude, _ := strconv.Atoi(tokens[i + 2])
+				angle, _ := strconv.Atoi(tokens[i + 3])
+				move = &rep.Move{Type: "thrust", ShipId: sid, Magnitude: magnitude, Angle: angle}
+				i += 3
+
+			case tokens[i] == "d" && i + 2 < len(tokens):
+				sid, _ := strconv.Atoi(tokens[i + 1])
+				plid, _ := strconv.Atoi(tokens[i + 2])
+				move = &rep.Move{Type: "dock", ShipId: sid, PlanetId: plid}
+				i += 2
+
+			case tokens[i] == "u" && i + 1 < len(tokens):
+				sid, _ := strconv.Atoi(tokens[i + 1])
+				move = &rep.Move{Type: "undock", ShipId: sid}
+				i += 1
+
+			default:
+				continue
+			}
+
+			move.Owner = pid
+			player_moves[move.ShipId] = move
+		}
+
+		moves[pid] = []map[int]*rep.Move{player_moves}
+	}
+
+	self.replay.Moves = append(self.replay.Moves, moves)
+}
+
+func (self *ReplayWriter) SetStats(pid int, stats *rep.PlayerStats) {
+	self.replay.Stats[pid] = stats
+}
+
+func (self *ReplayWriter) Save(filename string) error {
+	return self.replay.Save(filename)
+}
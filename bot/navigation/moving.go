@@ -0,0 +1,190 @@
+package navigation
+
+import (
+	"math"
+
+	hal "../core"
+)
+
+// The normal navigation treats everything in the avoid_list as a stationary circle. Here we also consider
+// ships we expect to move this turn ("movers"): our own ships that have already been given a thrust order,
+// and enemy ships, which we assume will repeat last turn's movement (Dx, Dy). A course is checked against
+// a mover with a swept-circle test, i.e. do the 2 ships come within collision range at any time t in [0, 1].
+// Our own ships without orders are still reconciled later by the pilot package's ResolveMoves(). All this is
+// only done if the player's Settings.AvoidMovers is set.
+
+const (
+	MOVER_RANGE = 16				// Ignore ships further away than this (2 * MAX_SPEED + radii, plus a bit)
+	MOVER_MARGIN = 0.2				// Added to the collision radius, since predictions are imperfect
+)
+
+type Mover struct {
+	Ship			*hal.Ship
+	Vx				float64
+	Vy				float64
+}
+
+func PredictedVelocity(game *hal.Game, ship *hal.Ship) (float64, float64, bool) {
+
+	// Returns false if we have no idea; i.e. the ship is ours but has no order yet.
+
+	if ship.DockedStatus != hal.UNDOCKED {
+		return 0, 0, true
+	}
+
+	if ship.Owner == game.Pid() {
+		order := game.CurrentOrder(ship)
+		if order.Type == hal.NO_ORDER {
+			return 0, 0, false
+		}
+		speed, degrees := order.Course()
+		radians := hal.DegToRad(float64(degrees))
+		return float64(speed) * math.Cos(radians), float64(speed) * math.Sin(radians), true
+	}
+
+	return ship.Dx, ship.Dy, true
+}
+
+func PredictMovers(game *hal.Game, ship *hal.Ship, avoid_list []hal.Entity) []*Mover {
+
+	// Ships in the avoid_list are already being avoided as stationary objects, so aren't included.
+	// Nor are probably doomed enemy ships, which likely won't get to move. (Ours hold still, and so
+	// are in the avoid_list.)
+
+	var ret []*Mover
+
+	already_avoided := make(map[*hal.Ship]bool)
+
+	for _, entity := range avoid_list {
+		if entity.Type() == hal.SHIP {
+			already_avoided[entity.(*hal.Ship)] = true
+		}
+	}
+
+	for _, other := range game.ShipsWithin(ship.X, ship.Y, MOVER_RANGE) {
+
+		if other == ship || (other.Owner != game.Pid() && other.DoomChance >= hal.RISK_NAV_IGNORE) || already_avoided[other] || other.DockedStatus != hal.UNDOCKED {
+			continue
+		}
+
+		vx, vy, ok := PredictedVelocity(game, other)
+
+		if ok == false {
+			continue
+		}
+
+		ret = append(ret, &Mover{other, vx, vy})
+	}
+
+	return ret
+}
+
+// ------------------------------------------------------------------------------------------------------------------------------------------
+
+func CheckMoverCollision(ship *hal.Ship, speed int, degrees int, mover *Mover) bool {
+	_, ok := mover_collision_time(ship, speed, degrees, mover)
+	return ok
+}
+
+func FirstMoverCollision(ship *hal.Ship, speed int, degrees int, movers []*Mover) (*Mover, bool) {
+
+	var first *Mover
+	first_time := 2.0
+
+	for _, mover := range movers {
+		t, ok := mover_collision_time(ship, speed, degrees, mover)
+		if ok && t < first_time {
+			first = mover
+			first_time = t
+		}
+	}
+
+	return first, first != nil
+}
+
+func mover_collision_time(ship *hal.Ship, speed int, degrees int, mover *Mover) (float64, bool) {
+
+	radians := hal.DegToRad(float64(degrees))
+	vx := float64(speed) * math.Cos(radians)
+	vy := float64(speed) * math.Sin(radians)
+
+	t, ok := hal.CollisionTime(hal.SHIP_RADIUS * 2 + MOVER_MARGIN, ship.X, ship.Y, vx, vy, mover.Ship.X, mover.Ship.Y, mover.Vx, mover.Vy)
+
+	if ok && t >= 0 && t <= 1 {
+		return t, true
+	}
+
+	return 0, false
+}
+
+func AvoidMovers(ship *hal.Ship, speed int, degrees int, avoid_list []hal.Entity, movers []*Mover, ns NavStacker) (int, int) {
+
+	// Given a course already chosen (which avoids the stationary things), adjust it if it would hit a mover.
+	// We look for the course that ends nearest to where the original would have, without hitting anything.
+	// Note that staying still is just another candidate, since a mover can hit a stationary ship.
+	// The search is a few hundred collision checks, so it gives up if the turn's budget runs out.
+
+	mover, ok := FirstMoverCollision(ship, speed, degrees, movers)
+
+	if ok == false {
+		return speed, degrees
+	}
+
+	ns.AddToNavStack("AvoidMovers(): course %v %v hits %v", speed, degrees, mover.Ship)
+
+	game := ns.GetGame()
+
+	wantx, wanty := hal.Projection(ship.X, ship.Y, float64(speed), degrees)
+
+	best_speed, best_degrees := -1, 0
+	best_dist := 999999.9
+
+	for s := 0; s <= hal.MAX_SPEED; s++ {
+
+		if game.Budget().Expired() {
+			ns.AddToNavStack("AvoidMovers(): out of time")
+			break
+		}
+
+		for offset := 0; offset < 360; offset += 5 {
+
+			if s == 0 && offset > 0 {
+				break
+			}
+
+			d := degrees + offset
+
+			endx, endy := hal.Projection(ship.X, ship.Y, float64(s), d)
+			dist := hal.Dist(endx, endy, wantx, wanty)
+
+			if dist >= best_dist {
+				continue
+			}
+
+			if s > 0 {
+				if game.InBounds(endx, endy) == false {
+					continue
+				}
+				if _, ok := FirstCollision(ship, float64(s), d, avoid_list); ok {
+					continue
+				}
+			}
+
+			if _, ok := FirstMoverCollision(ship, s, d, movers); ok {
+				continue
+			}
+
+			best_speed, best_degrees, best_dist = s, d, dist
+		}
+	}
+
+	if best_speed == -1 {
+		ns.AddToNavStack("AvoidMovers(): no safe course, keeping original")
+		return speed, degrees
+	}
+
+	best_degrees %= 360
+
+	ns.AddToNavStack("AvoidMovers(): adjusted to %v %v", best_speed, best_degrees)
+	return best_speed, best_degrees
+}
@@ -13,7 +13,19 @@ type NavStacker interface {
 	GetGame() *hal.Game
 }
 
-var Ignore_Collision_Dist float64 = 999		// Default means no collision ignored when pathing. But reduced by bot (100 default in bot).
+// A NavStacker may also want the waypoints GetCourseRecursive() dodges through, e.g. for drawing them.
+
+type WaypointRecorder interface {
+	AddWaypoint(p *hal.Point)
+}
+
+// Each player's navigation settings, from its flags. They're passed in rather than kept globally so that
+// several bots in one process (e.g. in a tournament) can differ.
+
+type Settings struct {
+	IgnoreCollisionDist		float64			// Collisions further away are ignored when pathing. 0 means never ignore.
+	AvoidMovers				bool			// See moving.go
+}
 
 // ------------------------------------------------------------------------------------------------------------------------------------------
 
@@ -52,11 +64,11 @@ func FirstCollision(ship *hal.Ship, distance float64, degrees int, possibles []h
 	return closest_ent, true
 }
 
-func GetCourse(ship *hal.Ship, target hal.Entity, avoid_list []hal.Entity, side Side, ns NavStacker) (int, int, error) {
-	return GetCourseRecursive(ship, target, avoid_list, 10, side, ns)
+func GetCourse(ship *hal.Ship, target hal.Entity, avoid_list []hal.Entity, side Side, settings *Settings, ns NavStacker) (int, int, error) {
+	return GetCourseRecursive(ship, target, avoid_list, 10, side, settings, ns)
 }
 
-func GetCourseRecursive(ship *hal.Ship, target hal.Entity, avoid_list []hal.Entity, depth int, side Side, ns NavStacker) (int, int, error) {
+func GetCourseRecursive(ship *hal.Ship, target hal.Entity, avoid_list []hal.Entity, depth int, side Side, settings *Settings, ns NavStacker) (int, int, error) {
 
 	// Try to navigate to (collide with) the target, but avoiding the list of entites,
 	// which could include the target. Returns: speed, angle, error
@@ -82,7 +94,7 @@ func GetCourseRecursive(ship *hal.Ship, target hal.Entity, avoid_list []hal.Enti
 
 	c, ok := FirstCollision(ship, distance, degrees, avoid_list)
 
-	if ok == false || ship.ApproachDist(c) > Ignore_Collision_Dist {			// There is no collision... or it's miles away (fixes replay 7710319)
+	if ok == false || (settings.IgnoreCollisionDist > 0 && ship.ApproachDist(c) > settings.IgnoreCollisionDist) {	// There is no collision... or it's miles away (fixes replay 7710319)
 		speed := hal.Min(hal.Round(distance), hal.MAX_SPEED)
 		ns.AddToNavStack("GetCourseRecursive(): succeeded with %v %v", speed, degrees)
 		return speed, degrees, nil
@@ -111,11 +123,15 @@ func GetCourseRecursive(ship *hal.Ship, target hal.Entity, avoid_list []hal.Enti
 	waypointx, waypointy := hal.Projection(c.GetX(), c.GetY(), c.GetRadius() + DODGE_MARGIN, waypoint_angle)
 	p := &hal.Point{waypointx, waypointy}
 
+	if wr, ok := ns.(WaypointRecorder); ok {
+		wr.AddWaypoint(p)
+	}
+
 	ns.AddToNavStack("GetCourseRecursive(): angle: %v; collision: %v; recursing with %v", degrees, c, p)
-	return GetCourseRecursive(ship, p, avoid_list, depth - 1, side, ns)
+	return GetCourseRecursive(ship, p, avoid_list, depth - 1, side, settings, ns)
 }
 
-func GetApproach(ship *hal.Ship, target hal.Entity, margin float64, avoid_list []hal.Entity, side Side, ns NavStacker) (int, int, error) {
+func GetApproach(ship *hal.Ship, target hal.Entity, margin float64, avoid_list []hal.Entity, side Side, settings *Settings, ns NavStacker) (int, int, error) {
 
 	// Navigate so that the ship's centre is definitely within <margin> of the target's edge.
 
@@ -132,5 +148,5 @@ func GetApproach(ship *hal.Ship, target hal.Entity, margin float64, avoid_list [
 	p := &hal.Point{target_point_x, target_point_y}
 
 	ns.AddToNavStack("GetApproach(): starting; side is %v, true target is %v, target is %v", side, target, p)
-	return GetCourse(ship, p, avoid_list, side, ns)
+	return GetCourse(ship, p, avoid_list, side, settings, ns)
 }
@@ -0,0 +1,371 @@
+package navigation
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	hal "../core"
+)
+
+// Multi-turn path planning around planets (and the ships docked at them, which never move while docked).
+// The path is found on a visibility graph: nodes are the start, the goal, and a ring of points around each
+// planet; edges are straight lines that don't pass through any planet. The path is then turned into a thrust
+// for each turn, found by simulating the ship steering for the next waypoint. Following the path is a matter of
+// making those thrusts, using the usual GetCourse() instead when something mobile is in the way.
+
+const (
+	PATH_RING_POINTS = 12
+	PATH_CLEARANCE = 0.6			// Added to planet radius + ship radius when testing lines
+	PATH_RING_CLEARANCE = 1.6		// Added to planet radius + ship radius for the ring (covers the chords between ring points)
+	PATH_REGION_MARGIN = 30			// How far outside the start/goal bounding box planets are considered
+	PATH_GOAL_TOLERANCE = 3.0		// How far the goal can move before the path is replanned
+	PATH_WAYPOINT_REACHED = 1.0
+	PATH_BUDGET_SHARE = 0.1			// Of the turn's remaining time, per call to the planner
+	PATH_MAX_TURNS = 70				// Most thrusts planned (enough to cross the largest map)
+	PATH_THRUST_MATCH = 0.01		// How close to a thrust's start point the ship must be to make it
+)
+
+type path_obstacle struct {
+	id				int
+	x				float64
+	y				float64
+	radius			float64			// Including docked ships
+}
+
+type Thrust struct {
+	Speed			int
+	Degrees			int
+	x				float64				// Where the ship should be when it makes this thrust
+	y				float64
+}
+
+type Path struct {
+	Goal			*hal.Point
+	Waypoints		[]*hal.Point		// Still to visit, ending with the goal
+	Thrusts			[]*Thrust			// Still to make, 1 per turn, ending at the goal
+	signature		string
+	region			[4]float64			// min x, min y, max x, max y
+}
+
+func (self *Path) String() string {
+	var parts []string
+	for _, p := range self.Waypoints {
+		parts = append(parts, fmt.Sprintf("[%d,%d]", int(p.X), int(p.Y)))
+	}
+	return fmt.Sprintf("Path %s (%d turns)", strings.Join(parts, " -> "), len(self.Thrusts))
+}
+
+// ------------------------------------------------------------------------------------------------------------------------------------------
+
+func PlanPath(game *hal.Game, ship *hal.Ship, goal hal.Entity) *Path {
+
+	// Always returns a path; if the planner fails (or runs out of time), the path is just the goal itself
+	// (i.e. a straight line). Such a path is usually invalid next turn, so will be replanned then.
+
+	ret := &Path{Goal: &hal.Point{goal.GetX(), goal.GetY()}}
+
+	ret.region = path_region(ship.X, ship.Y, ret.Goal.X, ret.Goal.Y)
+	obstacles := path_obstacles(game, ret.region)
+	ret.signature = obstacle_signature(obstacles)
+
+	deadline := game.Budget().Share(PATH_BUDGET_SHARE)
+	waypoints, ok := shortest_path(ship.X, ship.Y, ret.Goal.X, ret.Goal.Y, obstacles, float64(game.Width()), float64(game.Height()), deadline)
+
+	if ok {
+		ret.Waypoints = waypoints
+	} else {
+		ret.Waypoints = []*hal.Point{ret.Goal}
+	}
+
+	ret.Thrusts = plan_thrusts(ship.X, ship.Y, ret.Waypoints, obstacles)
+
+	return ret
+}
+
+func (self *Path) StillValid(game *hal.Game, ship *hal.Ship, goal hal.Entity) bool {
+
+	// The path is stale if the goal has moved, if the planets / docked ships near it have changed,
+	// or if we've been pushed somewhere we can't see our next waypoint from.
+
+	if hal.Dist(self.Goal.X, self.Goal.Y, goal.GetX(), goal.GetY()) > PATH_GOAL_TOLERANCE {
+		return false
+	}
+
+	obstacles := path_obstacles(game, self.region)
+
+	if obstacle_signature(obstacles) != self.signature {
+		return false
+	}
+
+	return line_is_clear(ship.X, ship.Y, self.Waypoints[0].X, self.Waypoints[0].Y, obstacles)
+}
+
+func (self *Path) NextWaypoint(game *hal.Game, ship *hal.Ship) *hal.Point {
+
+	// Drop waypoints we've reached, or can skip because a later one is directly visible. Returns the
+	// point to steer for this turn.
+
+	self.Waypoints = skip_waypoints(ship.X, ship.Y, self.Waypoints, path_obstacles(game, self.region))
+	return self.Waypoints[0]
+}
+
+func (self *Path) NextThrust(game *hal.Game, ship *hal.Ship) (*Thrust, bool) {
+
+	// This turn's thrust, dropping those for turns gone by. If the ship isn't where any of them expected it
+	// to be (it had to dodge something, say) the thrusts are worked out again from where it is, along the
+	// same waypoints. The bool is false iff the ship is already at the goal.
+
+	for n, thrust := range self.Thrusts {
+		if hal.Dist(ship.X, ship.Y, thrust.x, thrust.y) < PATH_THRUST_MATCH {
+			self.Thrusts = self.Thrusts[n:]
+			return thrust, true
+		}
+	}
+
+	self.Thrusts = plan_thrusts(ship.X, ship.Y, self.Waypoints, path_obstacles(game, self.region))
+
+	if len(self.Thrusts) == 0 {
+		return nil, false
+	}
+
+	return self.Thrusts[0], true
+}
+
+func (self *Path) OnLastLeg() bool {
+	return len(self.Waypoints) == 1
+}
+
+// ------------------------------------------------------------------------------------------------------------------------------------------
+
+func path_region(x1, y1, x2, y2 float64) [4]float64 {
+	return [4]float64{
+		math.Min(x1, x2) - PATH_REGION_MARGIN,
+		math.Min(y1, y2) - PATH_REGION_MARGIN,
+		math.Max(x1, x2) + PATH_REGION_MARGIN,
+		math.Max(y1, y2) + PATH_REGION_MARGIN,
+	}
+}
+
+func path_obstacles(game *hal.Game, region [4]float64) []*path_obstacle {
+
+	var ret []*path_obstacle
+
+	for _, planet := range game.AllPlanets() {
+
+		radius := planet.Radius
+
+		for _, docked := range game.ShipsDockedAt(planet) {
+			radius = math.Max(radius, planet.Dist(docked) + hal.SHIP_RADIUS)
+		}
+
+		if planet.X + radius < region[0] || planet.X - radius > region[2] || planet.Y + radius < region[1] || planet.Y - radius > region[3] {
+			continue
+		}
+
+		ret = append(ret, &path_obstacle{planet.Id, planet.X, planet.Y, radius})
+	}
+
+	return ret
+}
+
+func obstacle_signature(obstacles []*path_obstacle) string {
+
+	var parts []string
+
+	for _, o := range obstacles {
+		parts = append(parts, fmt.Sprintf("%d:%.1f", o.id, o.radius))
+	}
+
+	sort.Strings(parts)
+	return strings.Join(parts, " ")
+}
+
+func line_is_clear(x1, y1, x2, y2 float64, obstacles []*path_obstacle) bool {
+
+	// Obstacles containing either end are ignored; e.g. we may be sitting right next to a planet
+	// we want to dock at. The usual navigation deals with those.
+
+	for _, o := range obstacles {
+		r := o.radius + hal.SHIP_RADIUS + PATH_CLEARANCE
+		if hal.Dist(x1, y1, o.x, o.y) < r || hal.Dist(x2, y2, o.x, o.y) < r {
+			continue
+		}
+		if hal.IntersectSegmentCircle(x1, y1, x2, y2, o.x, o.y, r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func skip_waypoints(x, y float64, waypoints []*hal.Point, obstacles []*path_obstacle) []*hal.Point {
+
+	// Drop waypoints reached from (x, y), or skippable because a later one is directly visible.
+
+	for len(waypoints) > 1 {
+		if hal.Dist(x, y, waypoints[0].X, waypoints[0].Y) < PATH_WAYPOINT_REACHED {
+			waypoints = waypoints[1:]
+			continue
+		}
+		if line_is_clear(x, y, waypoints[1].X, waypoints[1].Y, obstacles) {
+			waypoints = waypoints[1:]
+			continue
+		}
+		break
+	}
+
+	return waypoints
+}
+
+func plan_thrusts(x, y float64, waypoints []*hal.Point, obstacles []*path_obstacle) []*Thrust {
+
+	// Follow the waypoints as NextWaypoint() would, a turn at a time, heading straight for the next one at
+	// whole number speeds and angles. The ship is moved just as the engine moves it, so the start points can
+	// be compared with where the ship really is later.
+
+	var ret []*Thrust
+
+	for len(ret) < PATH_MAX_TURNS {
+
+		waypoints = skip_waypoints(x, y, waypoints, obstacles)
+		next := waypoints[0]
+
+		speed := hal.Min(int(hal.Dist(x, y, next.X, next.Y)), hal.MAX_SPEED)
+
+		if speed == 0 {						// Only when within a unit of the goal, since nearer waypoints were skipped
+			break
+		}
+
+		degrees := hal.Angle(x, y, next.X, next.Y)
+
+		ret = append(ret, &Thrust{Speed: speed, Degrees: degrees, x: x, y: y})
+		x, y = hal.Projection(x, y, float64(speed), degrees)
+	}
+
+	return ret
+}
+
+// ------------------------------------------------------------------------------------------------------------------------------------------
+
+type path_node struct {
+	x				float64
+	y				float64
+	cost			float64
+	estimate		float64
+	parent			*path_node
+	done			bool
+	index			int					// In the heap
+}
+
+type node_heap []*path_node
+
+func (h node_heap) Len() int { return len(h) }
+func (h node_heap) Less(a, b int) bool { return h[a].cost + h[a].estimate < h[b].cost + h[b].estimate }
+func (h node_heap) Swap(a, b int) { h[a], h[b] = h[b], h[a]; h[a].index = a; h[b].index = b }
+func (h *node_heap) Push(x interface{}) { n := x.(*path_node); n.index = len(*h); *h = append(*h, n) }
+func (h *node_heap) Pop() interface{} { old := *h; n := old[len(old) - 1]; *h = old[:len(old) - 1]; return n }
+
+func shortest_path(startx, starty, goalx, goaly float64, obstacles []*path_obstacle, width, height float64, deadline time.Time) ([]*hal.Point, bool) {
+
+	// A* over the visibility graph. Edges are tested lazily, when a node is expanded. Each expansion tests
+	// every node against every obstacle, so we check the clock as we go.
+
+	if line_is_clear(startx, starty, goalx, goaly, obstacles) {
+		return []*hal.Point{&hal.Point{goalx, goaly}}, true
+	}
+
+	start := &path_node{x: startx, y: starty}
+	goal := &path_node{x: goalx, y: goaly, cost: math.Inf(1)}
+
+	nodes := []*path_node{goal}
+
+	for _, o := range obstacles {
+		r := o.radius + hal.SHIP_RADIUS + PATH_RING_CLEARANCE
+		for n := 0; n < PATH_RING_POINTS; n++ {
+			angle := 2 * math.Pi * float64(n) / PATH_RING_POINTS
+			x, y := o.x + r * math.Cos(angle), o.y + r * math.Sin(angle)
+			if point_is_free(x, y, obstacles, width, height) {
+				nodes = append(nodes, &path_node{x: x, y: y, cost: math.Inf(1)})
+			}
+		}
+	}
+
+	for _, node := range nodes {
+		node.estimate = hal.Dist(node.x, node.y, goalx, goaly)
+	}
+
+	start.estimate = hal.Dist(startx, starty, goalx, goaly)
+
+	open := &node_heap{}
+	heap.Push(open, start)
+
+	for open.Len() > 0 {
+
+		if time.Now().After(deadline) {
+			return nil, false
+		}
+
+		current := heap.Pop(open).(*path_node)
+		current.done = true
+
+		if current == goal {
+			break
+		}
+
+		for _, node := range nodes {
+
+			if node.done {
+				continue
+			}
+
+			cost := current.cost + hal.Dist(current.x, current.y, node.x, node.y)
+
+			if cost >= node.cost {
+				continue
+			}
+
+			if line_is_clear(current.x, current.y, node.x, node.y, obstacles) == false {
+				continue
+			}
+
+			already_open := node.cost < math.Inf(1)
+
+			node.cost = cost
+			node.parent = current
+
+			if already_open {
+				heap.Fix(open, node.index)
+			} else {
+				heap.Push(open, node)
+			}
+		}
+	}
+
+	if goal.parent == nil {
+		return nil, false
+	}
+
+	var ret []*hal.Point
+
+	for node := goal; node != start; node = node.parent {
+		ret = append([]*hal.Point{&hal.Point{node.x, node.y}}, ret...)
+	}
+
+	return ret, true
+}
+
+func point_is_free(x, y float64, obstacles []*path_obstacle, width, height float64) bool {
+	if x < hal.SHIP_RADIUS || y < hal.SHIP_RADIUS || x >= width - hal.SHIP_RADIUS || y >= height - hal.SHIP_RADIUS {
+		return false
+	}
+	for _, o := range obstacles {
+		if hal.Dist(x, y, o.x, o.y) < o.radius + hal.SHIP_RADIUS + PATH_CLEARANCE {
+			return false
+		}
+	}
+	return true
+}
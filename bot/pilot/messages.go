@@ -1,12 +1,21 @@
 package pilot
 
 import (
+	"fmt"
+
 	hal "../core"
 )
 
-const (
+// Each ship's order can carry a message, 0-180, hidden in its thrust angle (see Order.Command()) so that it
+// shows in the Chlorine viewer, and can be decoded from replays (see cmd/narrate). Messages up to MSG_MAX_ID
+// are the ID of whatever the ship's problem is about: normally a planet, but when rushing, a ship. Entities
+// with higher IDs get no message (see IdMessage()). The rest are codes, all of which were used at some point
+// in the bot's history; not all are used now.
+//
+// Every code must be in the registry below, which is what decoders use to name them.
 
-	// These were all used at some point in the bot's history. Not now though.
+const (
+	MSG_MAX_ID = 120
 
 	MSG_ATTACK_DOCKED = 121
 	MSG_ORBIT_FIGHT = 122
@@ -32,6 +41,86 @@ const (
 	MSG_NO_TARGET = 180
 )
 
+type MessageInfo struct {
+	Code			int
+	Name			string
+	Meaning			string
+	ShipTarget		bool						// The ship is after an enemy ship (not named by the message)
+}
+
+var message_registry = []*MessageInfo{
+	&MessageInfo{MSG_ATTACK_DOCKED, "attack-docked", "attacking docked ships", true},
+	&MessageInfo{MSG_ORBIT_FIGHT, "orbit-fight", "fighting near a planet", true},
+	&MessageInfo{MSG_ASSASSINATE, "assassinate", "chasing an interplanetary enemy ship", true},
+	&MessageInfo{MSG_ATC_DEACTIVATED, "atc-deactivated", "collision control switched off", false},
+	&MessageInfo{MSG_ATC_RESTRICT, "atc-restrict", "move restricted by collision control", false},
+	&MessageInfo{MSG_ATC_SLOWED, "atc-slowed", "slowed by collision control", false},
+	&MessageInfo{MSG_COWARD, "coward", "fleeing (weak in a 4 player game)", false},
+	&MessageInfo{MSG_RETREAT_FAILED, "retreat-failed", "couldn't find a way to flee", false},
+	&MessageInfo{MSG_PLANET_LOCKED, "planet-locked", "keeping a locked planet target", false},
+	&MessageInfo{MSG_SHIP_LOCKED, "ship-locked", "keeping a locked ship target", true},
+	&MessageInfo{MSG_POINT_LOCKED, "point-locked", "keeping a locked point target", false},
+	&MessageInfo{MSG_PORT_LOCKED, "port-locked", "keeping a locked docking target", false},
+	&MessageInfo{MSG_SHIP_LOCKED_FEARLESS, "ship-locked-fearless", "keeping a locked ship target, ignoring danger", true},
+	&MessageInfo{MSG_GLOBAL_SAUCE, "global-sauce", "moved by the GA (global)", false},
+	&MessageInfo{MSG_PERFECT_SAUCE, "perfect-sauce", "moved by the GA (perfect)", false},
+	&MessageInfo{MSG_DOCK_TARGET, "dock-target", "going to dock", false},
+	&MessageInfo{MSG_RECURSION, "recursion", "navigation gave up", false},
+	&MessageInfo{MSG_EXECUTED_NO_PLAN, "executed-no-plan", "order sent without a plan", false},
+	&MessageInfo{MSG_SECRET_SAUCE, "secret-sauce", "moved by the GA", false},
+	&MessageInfo{MSG_POINT_TARGET, "point-target", "heading for a point", false},
+	&MessageInfo{MSG_DOCK_APPROACH, "dock-approach", "approaching a planet to dock", false},
+	&MessageInfo{MSG_NO_TARGET, "no-target", "nothing to do", false},
+}
+
+var message_lookup map[int]*MessageInfo
+
+func init() {
+	message_lookup = make(map[int]*MessageInfo)
+	for _, info := range message_registry {
+		if info.Code <= MSG_MAX_ID || info.Code > 180 || message_lookup[info.Code] != nil {
+			panic(fmt.Sprintf("message registry: bad or duplicate code %d", info.Code))
+		}
+		message_lookup[info.Code] = info
+	}
+}
+
+func LookupMessage(message int) (*MessageInfo, bool) {
+
+	// false for IDs and for anything not registered.
+
+	info, ok := message_lookup[message]
+	return info, ok
+}
+
+func MessageName(message int) string {
+
+	if message < 0 {
+		return "none"
+	}
+	if message <= MSG_MAX_ID {
+		return fmt.Sprintf("id %d", message)
+	}
+	if info, ok := message_lookup[message]; ok {
+		return info.Name
+	}
+	return fmt.Sprintf("unknown %d", message)
+}
+
+func IdMessage(id int) int {
+
+	// The message naming an entity, or -1 (no message) if its ID is too high, since it would read as a code.
+
+	if id < 0 || id > MSG_MAX_ID {
+		return -1
+	}
+	return id
+}
+
+func AllMessages() []*MessageInfo {
+	return append([]*MessageInfo(nil), message_registry...)
+}
+
 func (self *Pilot) MessageWhileLocked() {
 
 	switch self.Target.Type() {
@@ -10,6 +10,14 @@ func (self *Pilot) PlanChase(avoid_list []hal.Entity, ignore_inhibition bool) {
 
 	self.ResetPlan()
 
+	if self.Game.Tracing(self.Id) {
+		nav_start := len(self.NavStack)
+		defer func() {
+			self.Trace("plan").With("target", self.Target).With("plan", self.Plan).With("fleeing", self.Fleeing).
+				With("ignore_inhibition", ignore_inhibition).With("avoiding", len(avoid_list)).With("nav_stack", self.NavStack[nav_start:])
+		}()
+	}
+
 	if self.DockedStatus != hal.UNDOCKED {
 		return
 	}
@@ -18,7 +26,7 @@ func (self *Pilot) PlanChase(avoid_list []hal.Entity, ignore_inhibition bool) {
 
 	case hal.NOTHING:
 
-		self.PlanThrust(0, 0)
+		self.PlanHold()
 
 	case hal.PLANET:
 
@@ -74,6 +82,7 @@ func (self *Pilot) EngageShip(other_ship *hal.Ship, avoid_list []hal.Entity, ign
 	// Protect it if it's friendly...
 
 	if other_ship.Owner == self.Game.Pid() {
+		self.Trace("engage").With("ship", other_ship).With("decision", "protect")
 		self.ProtectShip(other_ship, avoid_list)
 		return
 	}
@@ -81,11 +90,13 @@ func (self *Pilot) EngageShip(other_ship *hal.Ship, avoid_list []hal.Entity, ign
 	// Otherwise: sometimes approach, sometimes flee...
 
 	if ignore_inhibition {
+		self.Trace("engage").With("ship", other_ship).With("decision", "approach (ignoring inhibition)")
 		self.EngageShipApproach(other_ship, avoid_list)
 		return
 	}
 
-	if self.Firing && len(self.DangerShips) > 0 {
+	if self.FireChance >= hal.RISK_FIRING && len(self.DangerShips) > 0 {
+		self.Trace("engage").With("ship", other_ship).With("decision", "flee (firing anyway)").With("fire_chance", self.FireChance)
 		self.EngageShipFlee(other_ship, avoid_list)
 		return
 	}
@@ -98,6 +109,7 @@ func (self *Pilot) EngageShip(other_ship *hal.Ship, avoid_list []hal.Entity, ign
 		// Special case if the enemy ship is docked and there are no dangerous ships nearby...
 
 		if other_ship.DockedStatus != hal.UNDOCKED && len(self.DangerShips) == 0 {
+			self.Trace("engage").With("ship", other_ship).With("decision", "approach docked ship").With("inhibition", self.Inhibition)
 			self.EngageShipApproach(other_ship, avoid_list)
 			self.Log("Safe to ignore Inhibition and approach docked ship.")
 			return
@@ -107,6 +119,7 @@ func (self *Pilot) EngageShip(other_ship *hal.Ship, avoid_list []hal.Entity, ign
 		// FIXME: this isn't actually correct, an enemy docked ship could absorb some damage intended for our real target...
 
 		if other_ship.DockedStatus == hal.UNDOCKED && len(self.DangerShips) == 1 && other_ship.ShotsToKill() == 1 && self.ShotsToKill() > 1 {
+			self.Trace("engage").With("ship", other_ship).With("decision", "go for the kill").With("inhibition", self.Inhibition)
 			self.EngageShipApproach(other_ship, avoid_list)
 			self.Log("Safe to ignore Inhibition and go for the kill.")
 			return
@@ -114,10 +127,12 @@ func (self *Pilot) EngageShip(other_ship *hal.Ship, avoid_list []hal.Entity, ign
 
 		// But normally, flee...
 
+		self.Trace("engage").With("ship", other_ship).With("decision", "flee (inhibited)").With("inhibition", self.Inhibition)
 		self.EngageShipFlee(other_ship, avoid_list)
 		return
 	}
 
+	self.Trace("engage").With("ship", other_ship).With("decision", "approach").With("inhibition", self.Inhibition)
 	self.EngageShipApproach(other_ship, avoid_list)
 	return
 }
@@ -158,6 +173,7 @@ func (self *Pilot) EngageShipFlee(enemy_ship *hal.Ship, avoid_list []hal.Entity)
 	}
 
 	self.Fleeing = true
+	self.FleePoint = flee_point
 }
 
 func (self *Pilot) PlanetApproachForDock(planet *hal.Planet, avoid_list []hal.Entity) {
@@ -176,12 +192,20 @@ func (self *Pilot) PlanetApproachForDock(planet *hal.Planet, avoid_list []hal.En
 	}
 }
 
-func (self *Pilot) DetectDanger(all_ships []*hal.Ship) {
+func (self *Pilot) DetectDanger() {
 
 	self.Inhibition = 0
 	self.DangerShips = nil
 
-	for _, ship := range all_ships {
+	for _, ship := range self.Game.ShipsWithin(self.X, self.Y, DANGER_RANGE) {
+		if ship.Owner != self.Owner && ship.DockedStatus == hal.UNDOCKED && self.Dist(ship) < DANGER_RANGE {
+			self.DangerShips = append(self.DangerShips, ship)
+		}
+	}
+
+	// Inhibition is over every ship in the game, so doesn't use the index...
+
+	for _, ship := range self.Game.AllShips() {
 
 		if ship == self.Ship {
 			continue
@@ -195,12 +219,6 @@ func (self *Pilot) DetectDanger(all_ships []*hal.Ship) {
 
 		dist := self.Dist(ship)		// Consider: dist := hal.MaxFloat(5, self.Dist(ship)) // Don't let really close ships affect us too strongly...
 
-		if dist < 20 {
-			if ship.Owner != self.Owner && ship.DockedStatus == hal.UNDOCKED {
-				self.DangerShips = append(self.DangerShips, ship)
-			}
-		}
-
 		strength := 10000 / (dist * dist)
 
 		if ship.Owner == self.Owner {
@@ -209,4 +227,13 @@ func (self *Pilot) DetectDanger(all_ships []*hal.Ship) {
 
 		self.Inhibition += strength
 	}
+
+	if step := self.Trace("danger"); step != nil {
+		var danger_ids []int
+		for _, ship := range self.DangerShips {
+			danger_ids = append(danger_ids, ship.Id)
+		}
+		step.With("inhibition", self.Inhibition).With("danger_ships", danger_ids).With("fire_chance", self.FireChance).
+			With("doom_chance", self.DoomChance).With("death_chance", self.DeathChance)
+	}
 }
@@ -9,11 +9,12 @@ import (
 
 const (
 	DEFAULT_ENEMY_SHIP_APPROACH_DIST = 5.45			// GetApproach uses centre-to-edge distances, so 5.5ish.
+	DANGER_RANGE = 20								// Enemy ships closer than this go in DangerShips.
 )
 
 type Pilot struct {
 	*hal.Ship
-	Plan				string						// Our planned order, valid for 1 turn only.
+	Plan				hal.Order					// Our planned order, valid for 1 turn only.
 	Message				int							// Message for this turn. -1 for no message.
 	HasExecuted			bool						// Have we actually "sent" the order? (Placed it in the game.orders map.)
 	Game				*hal.Game
@@ -24,11 +25,16 @@ type Pilot struct {
 	Locked				bool						// Whether Target can change. Use super-sparingly.
 	DangerShips			[]*hal.Ship					// Enemy ships that could potentially shoot us this turn.
 	Fleeing				bool
+	FleePoint			*hal.Point					// Where EngageShipFlee() sent us, if it did. For drawing.
+	Waypoints			[]*hal.Point				// Dodges made by GetCourseRecursive() for the plan. For drawing.
+	Path				*nav.Path					// Multi-turn path around planets. Persists until invalid.
+	Nav					*nav.Settings				// The player's navigation settings.
 }
 
-func NewPilot(sid int, game *hal.Game) *Pilot {
+func NewPilot(sid int, game *hal.Game, settings *nav.Settings) *Pilot {
 	ret := new(Pilot)
 	ret.Game = game
+	ret.Nav = settings
 	ship, ok := game.GetShip(sid)
 	if ok == false {
 		panic("NewPilot called with invalid sid")
@@ -47,23 +53,33 @@ func (self *Pilot) AddToNavStack(format_string string, args ...interface{}) {
 	self.NavStack = append(self.NavStack, s)
 }
 
+func (self *Pilot) AddWaypoint(p *hal.Point) {
+	self.Waypoints = append(self.Waypoints, p)
+}
+
 func (self *Pilot) LogNavStack() {
-	self.Game.Log("%v Nav Stack:", self)
-	for _, s := range self.NavStack {
-		self.Game.Log("        %v", s)
-	}
+	self.Logger().With("nav_stack", self.NavStack).Debug("Nav Stack")
+}
+
+func (self *Pilot) Logger() *hal.Logger {
+	return self.Game.Logger("pilot").Ship(self.Id)
 }
 
 func (self *Pilot) Log(format_string string, args ...interface{}) {
-	format_string = fmt.Sprintf("%v: ", self) + format_string
-	self.Game.Log(format_string, args...)
+	self.Logger().Info(format_string, args...)
+}
+
+func (self *Pilot) Trace(stage string) *hal.TraceStep {			// nil unless we're being traced this turn
+	return self.Game.Trace(self.Id, stage)
 }
 
 func (self *Pilot) ResetPlan() {
-	self.Plan = ""
+	self.Plan = hal.Order{}
 	self.HasExecuted = false
-	self.Game.RawOrder(self.Id, "")
+	self.Game.ClearOrder(self.Ship)
 	self.Fleeing = false
+	self.FleePoint = nil
+	self.Waypoints = nil
 }
 
 func (self *Pilot) ResetAndUpdate() bool {				// Return true if we still exist.
@@ -116,15 +132,11 @@ func (self *Pilot) ResetAndUpdate() bool {				// Return true if we still exist.
 }
 
 func (self *Pilot) HasStationaryPlan() bool {		// true iff we DO have a plan, which doesn't move us.
-	if self.Plan == "" {
-		return false
-	}
-	speed, _ := self.CourseFromPlan()
-	return speed == 0
+	return self.Plan.Type != hal.NO_ORDER && self.Plan.Stationary()
 }
 
 func (self *Pilot) CourseFromPlan() (int, int) {
-	return hal.CourseFromString(self.Plan)
+	return self.Plan.Course()
 }
 
 func (self *Pilot) HasTarget() bool {				// We don't use nil ever, so we can e.g. call hal.Type()
@@ -142,59 +154,132 @@ func (self *Pilot) TargetDist() float64 {
 // -------------------------------------------------------------------
 
 func (self *Pilot) PlanThrust(speed, degrees int) {
-	for degrees < 0 { degrees += 360 }
-	degrees %= 360
-	self.Plan = fmt.Sprintf("t %d %d %d", self.Id, speed, degrees)
+	self.Plan = hal.Thrust(speed, degrees)
+}
+
+func (self *Pilot) PlanHold() {
+	self.Plan = hal.Hold()
 }
 
 func (self *Pilot) PlanDock(planet *hal.Planet) {
-	self.Plan = fmt.Sprintf("d %d %d", self.Id, planet.Id)
+	self.Plan = hal.Dock(planet.Id)
 }
 
 func (self *Pilot) PlanUndock() {
-	self.Plan = fmt.Sprintf("u %d", self.Id)
+	self.Plan = hal.Undock()
 }
 
 // -------------------------------------------------------------------
 
 func (self *Pilot) ExecutePlan() {
-	if self.Plan == "" {
-		self.PlanThrust(0, 0)
+	if self.Plan.Type == hal.NO_ORDER {
+		self.PlanHold()
 	}
-	self.Game.RawOrder(self.Id, self.Plan)
+	self.Game.SetOrder(self.Id, self.Plan)
 	self.Game.SetMessage(self.Ship, self.Message)			// Fails silently if message < 0 or > 180
 	self.HasExecuted = true
 }
 
 func (self *Pilot) ExecutePlanIfStationary() {
-	speed, _ := hal.CourseFromString(self.Plan)
-	if speed == 0 {
+	if self.Plan.Stationary() {
 		self.ExecutePlan()
 	}
 }
 
 func (self *Pilot) SlowPlanDown() {
 
-	speed, degrees := hal.CourseFromString(self.Plan)
-
-	if speed <= 1 {											// Don't slow our plan to zero, which is like having no plan.
+	if self.Plan.Type != hal.THRUST || self.Plan.Speed <= 1 {		// Don't slow our plan to zero, which is like having no plan.
 		return
 	}
 
-	speed--
-
-	self.PlanThrust(speed, degrees)
+	self.PlanThrust(self.Plan.Speed - 1, self.Plan.Degrees)
 	// self.Message = MSG_ATC_SLOWED
 }
 
 // -------------------------------------------------------------------
 
 func (self *Pilot) GetCourse(target hal.Entity, avoid_list []hal.Entity, side nav.Side) (int, int, error) {
-	return nav.GetCourse(self.Ship, target, avoid_list, side, self)
+
+	waypoint, final := self.PathWaypoint(target)
+
+	var speed, degrees int
+	var err error
+
+	if final {
+		speed, degrees, err = nav.GetCourse(self.Ship, target, avoid_list, side, self.Nav, self)
+	} else {
+		speed, degrees, err = self.PathCourse(waypoint, avoid_list)
+	}
+
+	return self.AvoidMovers(speed, degrees, err, avoid_list)
 }
 
 func (self *Pilot) GetApproach(target hal.Entity, margin float64, avoid_list []hal.Entity, side nav.Side) (int, int, error) {
-	return nav.GetApproach(self.Ship, target, margin, avoid_list, side, self)
+
+	// Only the last leg of the path is an approach; before that we just head for the waypoints.
+
+	waypoint, final := self.PathWaypoint(target)
+
+	var speed, degrees int
+	var err error
+
+	if final {
+		speed, degrees, err = nav.GetApproach(self.Ship, target, margin, avoid_list, side, self.Nav, self)
+	} else {
+		speed, degrees, err = self.PathCourse(waypoint, avoid_list)
+	}
+
+	return self.AvoidMovers(speed, degrees, err, avoid_list)
+}
+
+func (self *Pilot) AvoidMovers(speed, degrees int, err error, avoid_list []hal.Entity) (int, int, error) {
+
+	// If enabled, adjust a course so it doesn't hit ships that we expect to move this turn.
+
+	if err != nil || self.Nav.AvoidMovers == false {
+		return speed, degrees, err
+	}
+
+	movers := nav.PredictMovers(self.Game, self.Ship, avoid_list)
+	speed, degrees = nav.AvoidMovers(self.Ship, speed, degrees, avoid_list, movers, self)
+
+	return speed, degrees, nil
+}
+
+func (self *Pilot) PathWaypoint(target hal.Entity) (hal.Entity, bool) {
+
+	// Returns the point to head for this turn, replanning the cached path if needed.
+	// The bool is true iff that point is the target itself (i.e. this is the last leg).
+
+	if self.Path == nil || self.Path.StillValid(self.Game, self.Ship, target) == false {
+		self.Path = nav.PlanPath(self.Game, self.Ship, target)
+		self.AddToNavStack("PathWaypoint(): replanned: %v", self.Path)
+	}
+
+	waypoint := self.Path.NextWaypoint(self.Game, self.Ship)
+
+	if self.Path.OnLastLeg() {
+		return target, true
+	}
+
+	return waypoint, false
+}
+
+func (self *Pilot) PathCourse(waypoint hal.Entity, avoid_list []hal.Entity) (int, int, error) {
+
+	// Before the last leg: make the path's thrust for this turn, unless something we're avoiding is in the way,
+	// in which case steer for the waypoint around it as usual. The path's later thrusts are redone next turn.
+
+	thrust, ok := self.Path.NextThrust(self.Game, self.Ship)
+
+	if ok {
+		if _, collides := nav.FirstCollision(self.Ship, float64(thrust.Speed), thrust.Degrees, avoid_list); collides == false {
+			self.AddToNavStack("PathCourse(): planned thrust %v %v", thrust.Speed, thrust.Degrees)
+			return thrust.Speed, thrust.Degrees, nil
+		}
+	}
+
+	return nav.GetCourse(self.Ship, waypoint, avoid_list, nav.DecideSideFromTarget(self.Ship, waypoint, self.Game, self), self.Nav, self)
 }
 
 func (self *Pilot) DecideSideFor(target hal.Entity) nav.Side {
@@ -1,78 +1,415 @@
 package pilot
 
 import (
+	"fmt"
+	"sort"
+
 	hal "../core"
+	nav "../navigation"
 )
 
-func ExecuteSafely(mobile_pilots []*Pilot) {
+// ResolveMoves() decides the actual moves of all mobile pilots at once. Each pilot gets a short list of
+// options derived from its plan (the plan itself, slower or deflected versions of it, and staying still),
+// each valued by how close it gets to where the plan would have taken us. We then look for the choice of
+// options with the greatest total value such that no two of our ships collide.
+//
+// Pilots that can't possibly interact are solved separately. Within a group:
+//
+//   1. Greedy, in priority order (i.e. slice order), treating pilots not yet decided as stationary.
+//      This always succeeds, since staying still is always an option and never hits a stationary ship.
+//   2. Branch and bound from there, up to a node limit.
+//   3. Repeated passes letting each pilot upgrade its move if that doesn't hit anyone (for big groups
+//      where the search gave up).
+//
+// Assumption: anything not in mobile_pilots was already avoided during pathfinding, i.e. is in the avoid_list.
+
+const (
+	RESOLVE_NEIGHBOUR_DIST = 15				// Ships further apart than this can't collide (2 * MAX_SPEED + radii).
+	RESOLVE_MAX_OPTIONS = 10				// Including staying still.
+	RESOLVE_NODE_LIMIT = 20000				// Per group.
+)
+
+var resolve_offsets = []int{0, 10, -10, 20, -20, 35, -35, 50, -50, 70, -70, 90, -90}
+
+type move_option struct {
+	speed			int
+	degrees			int
+	value			float64
+}
+
+func (self *move_option) String() string {
+	return fmt.Sprintf("%d %d (%.2f)", self.speed, self.degrees, self.value)
+}
+
+type resolver struct {
+	game			*hal.Game
+	pilots			[]*Pilot
+	options			[][]*move_option
+	neighbours		[][]int
+	compat			map[[2]int][][]bool			// Key is [a, b] with a < b; value is indexed [option of a][option of b]
+	choice			[]int
+	best_choice		[]int
+	best_value		float64
+	nodes			int
+	trace			[]*hal.TraceStep			// Per pilot; nil unless it's being traced
+}
+
+func ResolveMoves(mobile_pilots []*Pilot, avoid_list []hal.Entity) {
 
 	if len(mobile_pilots) == 0 {
 		return
 	}
 
-	game := mobile_pilots[0].Game
-
-	// Assumption: we have already taken steps to ensure that any ship not included in the mobile_pilots
-	// is avoided, i.e. those ships were explicitly avoided in the earlier navigation search.
+	r := &resolver{
+		game: mobile_pilots[0].Game,
+		pilots: mobile_pilots,
+		compat: make(map[[2]int][][]bool),
+	}
 
-	for n := 0; n < 11; n++ {
+	for _, pilot := range mobile_pilots {
+		options := pilot.MoveOptions(avoid_list)
+		r.options = append(r.options, options)
+		step := pilot.Trace("resolve")
+		if step != nil {
+			var s []string
+			for _, option := range options {
+				s = append(s, option.String())
+			}
+			step.With("options", s)
+		}
+		r.trace = append(r.trace, step)
+	}
 
-		total_executes := 0
+	r.find_neighbours()
 
-		Pilot1Loop:
+	r.choice = make([]int, len(mobile_pilots))
+	r.best_choice = make([]int, len(mobile_pilots))
 
-		for _, pilot1 := range mobile_pilots {
+	for _, group := range r.groups() {
+		r.solve(group)
+	}
 
-			if pilot1.HasExecuted {
-				total_executes++
-				continue
+	for i, pilot := range mobile_pilots {
+		if pilot.HasExecuted {
+			continue
+		}
+		option := r.options[i][r.best_choice[i]]
+		r.trace[i].With("final", option).With("plan_stands", pilot.Plan.Stationary())
+		if pilot.Plan.Stationary() == false {						// Otherwise (e.g. docking) the plan stands.
+			if option.speed == 0 {
+				pilot.PlanHold()
+			} else {
+				pilot.PlanThrust(option.speed, option.degrees)
 			}
+		}
+		pilot.ExecutePlan()
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------------------------------------------
+
+func (self *Pilot) MoveOptions(avoid_list []hal.Entity) []*move_option {
+
+	// Sorted best first; usually the first is the plan itself. The last is always staying still.
+
+	if self.HasExecuted {
+		speed, degrees := self.Game.CurrentOrder(self.Ship).Course()
+		return []*move_option{&move_option{speed, degrees, float64(speed)}}
+	}
+
+	plan_speed, plan_degrees := self.CourseFromPlan()
+
+	hold := &move_option{0, 0, 0}
 
-			if n >= 5 {
-				pilot1.SlowPlanDown()
+	if plan_speed == 0 {
+		return []*move_option{hold}
+	}
+
+	// Only things we could reach this turn are relevant...
+
+	var nearby []hal.Entity
+
+	for _, entity := range avoid_list {
+		if self.ApproachDist(entity) < hal.MAX_SPEED + 1 {
+			nearby = append(nearby, entity)
+		}
+	}
+
+	var enemy_movers []*nav.Mover
+
+	if self.Nav.AvoidMovers {
+		for _, mover := range nav.PredictMovers(self.Game, self.Ship, avoid_list) {
+			if mover.Ship.Owner != self.Owner {
+				enemy_movers = append(enemy_movers, mover)
 			}
+		}
+	}
+
+	wantx, wanty := hal.Projection(self.X, self.Y, float64(plan_speed), plan_degrees)
+
+	var ret []*move_option
+
+	for speed := plan_speed; speed > 0; speed-- {
+
+		for _, offset := range resolve_offsets {
 
-			pilot1_desired_speed, pilot1_desired_angle := pilot1.CourseFromPlan()
-			msg1 := pilot1.Message
+			degrees := (plan_degrees + offset + 360) % 360
 
-			if game.CourseStaysInBounds(pilot1.Ship, pilot1_desired_speed, pilot1_desired_angle) == false {
+			endx, endy := hal.Projection(self.X, self.Y, float64(speed), degrees)
+			value := float64(plan_speed) - hal.Dist(endx, endy, wantx, wanty)
+
+			if value <= 0 {
 				continue
 			}
 
-			for _, pilot2 := range mobile_pilots {
+			if self.Game.InBounds(endx, endy) == false {
+				continue
+			}
 
-				if pilot2 == pilot1 {
+			if offset != 0 || speed != plan_speed {							// Trust the pathfinding for the plan itself.
+				if _, ok := nav.FirstCollision(self.Ship, float64(speed), degrees, nearby); ok {
 					continue
 				}
+			}
 
-				if pilot2.Dist(pilot1) > 15 {
-					continue
-				}
+			if _, ok := nav.FirstMoverCollision(self.Ship, speed, degrees, enemy_movers); ok {
+				continue
+			}
 
-				if pilot2.Doomed {
-					continue
+			ret = append(ret, &move_option{speed, degrees, value})
+		}
+	}
+
+	sort.SliceStable(ret, func(a, b int) bool {
+		return ret[a].value > ret[b].value
+	})
+
+	if len(ret) > RESOLVE_MAX_OPTIONS - 1 {
+		ret = ret[:RESOLVE_MAX_OPTIONS - 1]
+	}
+
+	return append(ret, hold)
+}
+
+// ------------------------------------------------------------------------------------------------------------------------------------------
+
+func (self *resolver) find_neighbours() {
+
+	self.neighbours = make([][]int, len(self.pilots))
+
+	for a := 0; a < len(self.pilots); a++ {
+		for b := a + 1; b < len(self.pilots); b++ {
+			if self.pilots[a].Dist(self.pilots[b]) <= RESOLVE_NEIGHBOUR_DIST {
+				self.neighbours[a] = append(self.neighbours[a], b)
+				self.neighbours[b] = append(self.neighbours[b], a)
+			}
+		}
+	}
+}
+
+func (self *resolver) groups() [][]int {
+
+	// Connected components of the neighbour graph. Each group is in priority order.
+
+	var ret [][]int
+
+	seen := make([]bool, len(self.pilots))
+
+	for i := range self.pilots {
+
+		if seen[i] {
+			continue
+		}
+
+		seen[i] = true
+		group := []int{i}
+
+		for n := 0; n < len(group); n++ {
+			for _, j := range self.neighbours[group[n]] {
+				if seen[j] == false {
+					seen[j] = true
+					group = append(group, j)
 				}
+			}
+		}
+
+		sort.Ints(group)
+		ret = append(ret, group)
+	}
+
+	return ret
+}
+
+func (self *resolver) compatible(a, oa, b, ob int) bool {
+
+	if a > b {
+		a, oa, b, ob = b, ob, a, oa
+	}
+
+	key := [2]int{a, b}
+
+	table, ok := self.compat[key]
+
+	if ok == false {
+
+		pa, pb := self.pilots[a], self.pilots[b]
+
+		table = make([][]bool, len(self.options[a]))
+
+		for i, option_a := range self.options[a] {
+			table[i] = make([]bool, len(self.options[b]))
+			for j, option_b := range self.options[b] {
+				table[i][j] = hal.ShipsWillCollide(pa.Ship, option_a.speed, option_a.degrees, pa.Message, pb.Ship, option_b.speed, option_b.degrees, pb.Message) == false
+			}
+		}
+
+		self.compat[key] = table
+	}
+
+	return table[oa][ob]
+}
+
+func (self *resolver) hold(i int) int {
+	return len(self.options[i]) - 1
+}
 
-				pilot2_speed := 0
-				pilot2_angle := 0
+// ------------------------------------------------------------------------------------------------------------------------------------------
 
-				if pilot2.HasExecuted {
-					pilot2_speed, pilot2_angle = hal.CourseFromString(game.CurrentOrder(pilot2.Ship))
+func (self *resolver) solve(group []int) {
+
+	position := make(map[int]int)					// Pilot index --> position in group
+	for n, i := range group {
+		position[i] = n
+	}
+
+	// 1. Greedy...
+
+	for n, i := range group {
+		self.choice[i] = self.hold(i)
+		for o := range self.options[i] {
+			ok := true
+			for _, j := range self.neighbours[i] {
+				if position[j] < n {
+					ok = self.compatible(i, o, j, self.choice[j])
+				} else {
+					ok = self.compatible(i, o, j, self.hold(j))
+				}
+				if ok == false {
+					break
 				}
+			}
+			if ok {
+				self.choice[i] = o
+				break
+			}
+		}
+	}
 
-				msg2 := pilot2.Message
+	for _, i := range group {
+		self.best_choice[i] = self.choice[i]
+		self.trace[i].With("group_size", len(group)).With("greedy", self.options[i][self.choice[i]])
+	}
 
-				if hal.ShipsWillCollide(pilot1.Ship, pilot1_desired_speed, pilot1_desired_angle, msg1, pilot2.Ship, pilot2_speed, pilot2_angle, msg2) {
-					continue Pilot1Loop
+	self.best_value = self.group_value(group, self.best_choice)
+
+	// 2. Branch and bound...
+
+	if len(group) > 1 {
+
+		remaining_best := make([]float64, len(group) + 1)			// Sum of best possible values from position n onwards
+		for n := len(group) - 1; n >= 0; n-- {
+			remaining_best[n] = remaining_best[n + 1] + self.options[group[n]][0].value
+		}
+
+		self.nodes = 0
+		self.search(group, position, 0, 0, remaining_best)
+
+		for _, i := range group {
+			self.trace[i].With("searched", self.options[i][self.best_choice[i]]).With("nodes", self.nodes)
+		}
+	}
+
+	// 3. Improvement passes...
+
+	for pass := 0; pass < 5; pass++ {
+
+		improved := false
+
+		for _, i := range group {
+			for o := 0; o < self.best_choice[i]; o++ {			// Options are sorted, so only earlier ones are better.
+				ok := true
+				for _, j := range self.neighbours[i] {
+					if self.compatible(i, o, j, self.best_choice[j]) == false {
+						ok = false
+						break
+					}
+				}
+				if ok {
+					self.best_choice[i] = o
+					improved = true
+					break
 				}
 			}
+		}
 
-			pilot1.ExecutePlan()
-			total_executes++
+		if improved == false {
+			break
 		}
+	}
+
+	for _, i := range group {
+		self.trace[i].With("improved", self.options[i][self.best_choice[i]])
+	}
+}
+
+func (self *resolver) search(group []int, position map[int]int, n int, value float64, remaining_best []float64) {
 
-		if total_executes >= len(mobile_pilots) {
-			return
+	if n == len(group) {
+		if value > self.best_value {
+			self.best_value = value
+			for _, i := range group {
+				self.best_choice[i] = self.choice[i]
+			}
 		}
+		return
+	}
+
+	self.nodes++
+
+	if self.nodes > RESOLVE_NODE_LIMIT {
+		return
+	}
+
+	if self.nodes % 1000 == 0 && self.game.Budget().Expired() {
+		self.nodes = RESOLVE_NODE_LIMIT
+		return
+	}
+
+	i := group[n]
+
+	OptionLoop:
+
+	for o, option := range self.options[i] {
+
+		if value + option.value + remaining_best[n + 1] <= self.best_value + 0.0001 {
+			return													// Options are sorted, so no later one can do better either.
+		}
+
+		for _, j := range self.neighbours[i] {
+			if position[j] < n && self.compatible(i, o, j, self.choice[j]) == false {
+				continue OptionLoop
+			}
+		}
+
+		self.choice[i] = o
+		self.search(group, position, n + 1, value + option.value, remaining_best)
+	}
+}
+
+func (self *resolver) group_value(group []int, choice []int) float64 {
+	total := 0.0
+	for _, i := range group {
+		total += self.options[i][choice[i]].value
 	}
+	return total
 }
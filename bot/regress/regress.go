@@ -0,0 +1,272 @@
+package regress
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	ai "../ai"
+	hal "../core"
+	gen "../genetic"
+	rep "../replay"
+)
+
+// Regression cases: replay the frames of a reference replay into a fresh Overmind, from the point of
+// view of our bot, up to some turn; then check what the Overmind decided to do on that turn. All
+// earlier turns are also stepped through, since the Overmind's state (rush choice, pilots...) depends
+// on them. The orders sent on earlier turns don't matter, since the replay decides what happened.
+
+const DEFAULT_PLAYER = "fohristiwhirl"
+
+var Checks = map[string]string{
+	"rushing":			"the Overmind has decided to rush",
+	"not_rushing":		"the Overmind is not rushing (or hasn't decided)",
+	"ga":				"the Overmind entered the GA this turn",
+	"no_ga":			"the Overmind didn't enter the GA this turn",
+	"coward":			"the coward flag is set",
+	"no_coward":		"the coward flag is not set",
+	"no_self_losses":	"no ship of ours dies this turn from our own orders (collisions, planets, edges)",
+}
+
+type Case struct {
+	Name			string			`json:"name"`
+	Replay			string			`json:"replay"`				// Relative to the cases file
+	Player			string			`json:"player,omitempty"`		// Substring of the player name (default: DEFAULT_PLAYER)
+	Turn			int				`json:"turn"`
+	Flags			string			`json:"flags,omitempty"`		// Bot flags, e.g. "-conservative -icd 999"
+	Expect			[]string		`json:"expect,omitempty"`		// Names of checks, see above
+	Golden			string			`json:"golden,omitempty"`		// Expected RawOutput(true, true); "" means not checked
+	Why				string			`json:"why,omitempty"`
+}
+
+type Outcome struct {
+	Case			*Case
+	Output			string			// RawOutput(true, true)
+	Rushing			bool
+	GA				bool
+	Coward			bool
+	SelfLosses		[]int
+	Failures		[]string
+	Err				error
+}
+
+func (self *Outcome) Passed() bool {
+	return self.Err == nil && len(self.Failures) == 0
+}
+
+// ------------------------------------------------------------------------------------------------
+
+func CasesFile() string {
+
+	// The cases.json next to this source file, so the cases can be found from anywhere.
+
+	_, source, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(source), "cases.json")
+}
+
+func LoadCases(filename string) ([]*Case, error) {
+
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []*Case
+
+	err = json.Unmarshal(raw, &cases)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range cases {
+		for _, check := range c.Expect {
+			if _, ok := Checks[check]; ok == false {
+				return nil, fmt.Errorf("case \"%s\": unknown check \"%s\"", c.Name, check)
+			}
+		}
+	}
+
+	return cases, nil
+}
+
+func SaveCases(filename string, cases []*Case) error {
+
+	raw, err := json.MarshalIndent(cases, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, append(raw, '\n'), 0644)
+}
+
+// ------------------------------------------------------------------------------------------------
+
+func (self *Case) Run(dir string) (outcome *Outcome) {
+
+	// dir is the directory of the cases file. Panics in the bot are reported as errors.
+
+	outcome = &Outcome{Case: self}
+
+	defer func() {
+		if p := recover(); p != nil {
+			outcome.Err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+
+	replay, err := rep.Load(filepath.Join(dir, self.Replay))
+	if err != nil {
+		outcome.Err = err
+		return outcome
+	}
+
+	pid, err := find_player(replay, self.Player)
+	if err != nil {
+		outcome.Err = err
+		return outcome
+	}
+
+	if self.Turn < 0 || self.Turn >= len(replay.Frames) {
+		outcome.Err = fmt.Errorf("turn %d is not in the replay (%d frames)", self.Turn, len(replay.Frames))
+		return outcome
+	}
+
+	config, err := self.config()
+	if err != nil {
+		outcome.Err = err
+		return outcome
+	}
+
+	playback := replay.NewPlayback(pid)
+	game := playback.Game()
+	overmind := ai.NewOvermind(game, config)
+
+	for playback.Turn() < self.Turn {
+		playback.Next()
+		if config.Timeseed == false {
+			rand.Seed(int64(game.Turn() + game.Width() + game.Pid()))		// As MyBot.go does.
+		}
+		overmind.Step()
+	}
+
+	game.ValidateOrders(config.NoMsg)						// As Game.Send() does.
+
+	outcome.Output = game.RawOutput(true, true)
+	outcome.Rushing = overmind.RushChoice == ai.RUSHING
+	outcome.GA = overmind.LastGATurn == game.Turn()
+	outcome.Coward = overmind.CowardFlag
+	outcome.SelfLosses = self_losses(game)
+
+	for _, check := range self.Expect {
+		if outcome.passes(check) == false {
+			outcome.Failures = append(outcome.Failures, fmt.Sprintf("%s: expected %s", check, Checks[check]))
+		}
+	}
+
+	if self.Golden != "" && self.Golden != outcome.Output {
+		outcome.Failures = append(outcome.Failures, fmt.Sprintf("golden: output was \"%s\"", outcome.Output))
+	}
+
+	return outcome
+}
+
+func (self *Outcome) passes(check string) bool {
+	switch check {
+	case "rushing":
+		return self.Rushing
+	case "not_rushing":
+		return self.Rushing == false
+	case "ga":
+		return self.GA
+	case "no_ga":
+		return self.GA == false
+	case "coward":
+		return self.Coward
+	case "no_coward":
+		return self.Coward == false
+	case "no_self_losses":
+		return len(self.SelfLosses) == 0
+	}
+	return false
+}
+
+func (self *Case) config() (*ai.Config, error) {
+
+	// Parse the flags as MyBot would.
+
+	config := new(ai.Config)
+
+	fs := flag.NewFlagSet(self.Name, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	config.RegisterFlags(fs)
+
+	err := fs.Parse(strings.Fields(self.Flags))
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func find_player(replay *rep.Replay, name string) (int, error) {
+
+	if name == "" {
+		name = DEFAULT_PLAYER
+	}
+
+	found := -1
+
+	for pid, player_name := range replay.PlayerNames {
+		if strings.Contains(strings.ToLower(player_name), strings.ToLower(name)) {
+			if found != -1 {
+				return -1, fmt.Errorf("player \"%s\" is ambiguous", name)
+			}
+			found = pid
+		}
+	}
+
+	if found == -1 {
+		return -1, fmt.Errorf("player \"%s\" not found", name)
+	}
+
+	return found, nil
+}
+
+func self_losses(game *hal.Game) []int {
+
+	// Simulate the turn with every other player removed, so that any ship we lose was lost to our
+	// own orders: ramming each other, ramming planets, or leaving the map. Our probably doomed ships
+	// stay in, since they may survive; navigation and ValidateOrders() both treat them as obstacles.
+
+	sim := gen.NewTurnSim(game)
+
+	for pid := 0; pid < sim.Players(); pid++ {
+		if pid != game.Pid() {
+			sim.RemovePlayer(pid)
+		}
+	}
+
+	survivors := make(map[int]bool)
+
+	sim.Step(map[int]string{game.Pid(): game.RawOutput(false, true)})
+
+	for _, ship := range sim.Ships() {
+		survivors[ship.Id] = true
+	}
+
+	var ret []int
+
+	for _, ship := range game.MyShips() {
+		if survivors[ship.Id] == false {
+			ret = append(ret, ship.Id)
+		}
+	}
+
+	sort.Ints(ret)
+	return ret
+}
@@ -0,0 +1,48 @@
+package regress
+
+import (
+	"os/exec"
+	"testing"
+)
+
+// Runs every case in cases.json, which is found relative to this package (go test runs in its directory).
+// The reference replays are zstd-compressed, so without the zstd command there's nothing to run.
+
+func TestCases(t *testing.T) {
+
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd is not installed, so the reference replays can't be read")
+	}
+
+	cases, err := LoadCases("cases.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+
+		c := c
+
+		t.Run(c.Name, func(t *testing.T) {
+
+			outcome := c.Run(".")
+
+			if outcome.Err != nil {
+				t.Fatal(outcome.Err)
+			}
+
+			for _, failure := range outcome.Failures {
+				t.Error(failure)
+			}
+
+			if t.Failed() {
+				if len(outcome.SelfLosses) > 0 {
+					t.Logf("self losses: %v", outcome.SelfLosses)
+				}
+				if c.Why != "" {
+					t.Logf("why: %s", c.Why)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+)
+
+// Rasterising by hand: for every pixel near a shape, how far its centre is from the shape decides whether
+// it's painted. No anti-aliasing, but it's only for looking at. Text is skipped.
+
+const DASH_PIXELS = 6
+
+type png_canvas struct {
+	img				*image.RGBA
+	scale			float64
+}
+
+func new_png_canvas(width, height int, scale float64) *png_canvas {
+
+	img := image.NewRGBA(image.Rect(0, 0, int(math.Ceil(float64(width) * scale)), int(math.Ceil(float64(height) * scale))))
+
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i + 1], img.Pix[i + 2], img.Pix[i + 3] = BACKGROUND.R, BACKGROUND.G, BACKGROUND.B, 255
+	}
+
+	return &png_canvas{img, scale}
+}
+
+func (self *png_canvas) draw(shapes []*shape) {
+	for _, s := range shapes {
+		switch s.kind {
+		case CIRCLE:
+			self.circle(s.x1 * self.scale, s.y1 * self.scale, s.r * self.scale, s.fill, s.stroke, s.width)
+		case LINE:
+			self.line(s.x1 * self.scale, s.y1 * self.scale, s.x2 * self.scale, s.y2 * self.scale, s.stroke, s.width, s.dashed)
+		}
+	}
+}
+
+func (self *png_canvas) encode(w io.Writer) error {
+	return png.Encode(w, self.img)
+}
+
+func (self *png_canvas) circle(cx, cy, r float64, fill, stroke color.RGBA, width float64) {
+
+	r = math.Max(r, 1)					// Always visible, however far out we're zoomed
+	outer := r + width / 2
+
+	x1, y1, x2, y2 := self.clip(cx - outer, cy - outer, cx + outer, cy + outer)
+
+	for py := y1; py <= y2; py++ {
+		for px := x1; px <= x2; px++ {
+			d := math.Hypot(float64(px) + 0.5 - cx, float64(py) + 0.5 - cy)
+			if stroke.A > 0 && math.Abs(d - r) <= width / 2 {
+				self.blend(px, py, stroke)
+			} else if fill.A > 0 && d < r {
+				self.blend(px, py, fill)
+			}
+		}
+	}
+}
+
+func (self *png_canvas) line(ax, ay, bx, by float64, stroke color.RGBA, width float64, dashed bool) {
+
+	half := math.Max(width / 2, 0.5)
+	length := math.Hypot(bx - ax, by - ay)
+
+	x1, y1, x2, y2 := self.clip(math.Min(ax, bx) - half, math.Min(ay, by) - half, math.Max(ax, bx) + half, math.Max(ay, by) + half)
+
+	for py := y1; py <= y2; py++ {
+		for px := x1; px <= x2; px++ {
+
+			x, y := float64(px) + 0.5, float64(py) + 0.5
+
+			// Distance from the pixel centre to the segment, and how far along it the nearest point is...
+
+			along := 0.0
+			if length > 0 {
+				along = math.Max(0, math.Min(length, ((x - ax) * (bx - ax) + (y - ay) * (by - ay)) / length))
+			}
+
+			nx, ny := ax, ay
+			if length > 0 {
+				nx, ny = ax + (bx - ax) * along / length, ay + (by - ay) * along / length
+			}
+
+			if math.Hypot(x - nx, y - ny) > half {
+				continue
+			}
+
+			if dashed && int(along / DASH_PIXELS) % 2 == 1 {
+				continue
+			}
+
+			self.blend(px, py, stroke)
+		}
+	}
+}
+
+func (self *png_canvas) clip(x1, y1, x2, y2 float64) (int, int, int, int) {
+	bounds := self.img.Bounds()
+	return int(math.Max(0, math.Floor(x1))), int(math.Max(0, math.Floor(y1))),
+	       int(math.Min(float64(bounds.Max.X - 1), math.Ceil(x2))), int(math.Min(float64(bounds.Max.Y - 1), math.Ceil(y2)))
+}
+
+func (self *png_canvas) blend(px, py int, c color.RGBA) {
+
+	i := self.img.PixOffset(px, py)
+	a := float64(c.A) / 255
+
+	self.img.Pix[i] = uint8(float64(c.R) * a + float64(self.img.Pix[i]) * (1 - a))
+	self.img.Pix[i + 1] = uint8(float64(c.G) * a + float64(self.img.Pix[i + 1]) * (1 - a))
+	self.img.Pix[i + 2] = uint8(float64(c.B) * a + float64(self.img.Pix[i + 2]) * (1 - a))
+}
@@ -0,0 +1,293 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	hal "../core"
+	pil "../pilot"
+)
+
+// Draws a Game, as it stands on some turn, to PNG or SVG, with only the standard library: planets in their
+// owners' colours with their docking spots, ships with HP bars, weapons range circles, and the risk markers.
+// If our Pilots are given, what they were thinking goes on top: lines to their targets, where they fled to,
+// the waypoints navigation dodged through, their multi-turn paths, and the moves actually ordered.
+//
+// Everything is first turned into a list of shapes in game coordinates (see build()), which each format then
+// draws its own way. Since the standard library has no fonts, only SVGs get text labels.
+//
+//     scene := render.NewScene(game, overmind.Pilots)
+//     err := scene.WriteFile("turn50.png")
+
+const (
+	CIRCLE = iota
+	LINE
+	TEXT
+)
+
+var (
+	BACKGROUND = color.RGBA{12, 12, 24, 255}
+	NEUTRAL = color.RGBA{110, 110, 110, 255}
+	WHITE = color.RGBA{255, 255, 255, 255}
+	TARGET_COLOUR = color.RGBA{80, 220, 255, 200}
+	FLEE_COLOUR = color.RGBA{255, 80, 255, 220}
+	WAYPOINT_COLOUR = color.RGBA{255, 255, 255, 160}
+	PATH_COLOUR = color.RGBA{120, 160, 255, 120}
+	MOVE_COLOUR = color.RGBA{255, 255, 255, 255}
+	FIRING_COLOUR = color.RGBA{255, 220, 0, 255}
+	DOOMED_COLOUR = color.RGBA{255, 30, 30, 255}
+	HP_BAR_BACK = color.RGBA{60, 60, 60, 255}
+)
+
+var PLAYER_COLOURS = []color.RGBA{
+	color.RGBA{230, 130, 40, 255},		// Orange
+	color.RGBA{80, 190, 70, 255},		// Green
+	color.RGBA{220, 60, 60, 255},		// Red
+	color.RGBA{160, 100, 230, 255},		// Purple
+}
+
+type Options struct {
+	Scale			float64					// Pixels per unit of game distance
+	WeaponRanges	bool
+	Labels			bool					// IDs and HP as text (SVG only)
+}
+
+type Scene struct {
+	Game			*hal.Game
+	Pilots			[]*pil.Pilot			// Can be empty; then there's no overlay of our thinking
+	Options			Options
+}
+
+type shape struct {
+	kind			int
+	x1, y1			float64					// Centre, for circles and text
+	x2, y2			float64
+	r				float64
+	fill			color.RGBA				// Alpha 0 for none
+	stroke			color.RGBA				// Alpha 0 for none
+	width			float64					// Stroke width, in pixels
+	dashed			bool
+	text			string
+}
+
+func NewScene(game *hal.Game, pilots []*pil.Pilot) *Scene {
+	return &Scene{
+		Game: game,
+		Pilots: pilots,
+		Options: Options{
+			Scale: 4,
+			WeaponRanges: true,
+			Labels: true,
+		},
+	}
+}
+
+func (self *Scene) WriteFile(filename string) error {
+
+	// The format is chosen by the extension, .png or .svg
+
+	var write func(io.Writer) error
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".png":
+		write = self.PNG
+	case ".svg":
+		write = self.SVG
+	default:
+		return fmt.Errorf("WriteFile(): unknown image format for %s", filename)
+	}
+
+	outfile, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = write(outfile)
+
+	if close_err := outfile.Close(); err == nil {
+		err = close_err
+	}
+	return err
+}
+
+func (self *Scene) PNG(w io.Writer) error {
+	c := new_png_canvas(self.Game.Width(), self.Game.Height(), self.Options.Scale)
+	c.draw(self.build())
+	return c.encode(w)
+}
+
+func (self *Scene) SVG(w io.Writer) error {
+	c := new_svg_canvas(self.Game.Width(), self.Game.Height(), self.Options.Scale)
+	c.draw(self.build())
+	return c.encode(w)
+}
+
+func PlayerColour(pid int) color.RGBA {
+	if pid < 0 || pid >= len(PLAYER_COLOURS) {
+		return NEUTRAL
+	}
+	return PLAYER_COLOURS[pid]
+}
+
+func fade(c color.RGBA, alpha uint8) color.RGBA {
+	c.A = alpha
+	return c
+}
+
+// ------------------------------------------------------------------------------------------------
+
+func (self *Scene) build() []*shape {
+
+	// In drawing order, i.e. later shapes go on top.
+
+	var ret []*shape
+
+	circle := func(x, y, r float64, fill, stroke color.RGBA, width float64) {
+		ret = append(ret, &shape{kind: CIRCLE, x1: x, y1: y, r: r, fill: fill, stroke: stroke, width: width})
+	}
+
+	line := func(x1, y1, x2, y2 float64, stroke color.RGBA, width float64, dashed bool) {
+		ret = append(ret, &shape{kind: LINE, x1: x1, y1: y1, x2: x2, y2: y2, stroke: stroke, width: width, dashed: dashed})
+	}
+
+	text := func(x, y float64, colour color.RGBA, format_string string, args ...interface{}) {
+		if self.Options.Labels {
+			ret = append(ret, &shape{kind: TEXT, x1: x, y1: y, fill: colour, text: fmt.Sprintf(format_string, args...)})
+		}
+	}
+
+	game := self.Game
+
+	// Weapons range first, so everything else is on top...
+
+	if self.Options.WeaponRanges {
+		for _, ship := range game.AllShips() {
+			if ship.DockedStatus == hal.UNDOCKED {
+				circle(ship.X, ship.Y, hal.WEAPON_RANGE + hal.SHIP_RADIUS, fade(PlayerColour(ship.Owner), 18), fade(PlayerColour(ship.Owner), 70), 1)
+			}
+		}
+	}
+
+	// Planets, with a ring for the docking radius, and a dot for each docking spot (filled if taken)...
+
+	for _, planet := range game.AllPlanets() {
+
+		owner := -1
+		if planet.Owned {
+			owner = planet.Owner
+		}
+		colour := PlayerColour(owner)
+
+		circle(planet.X, planet.Y, planet.Radius + hal.DOCKING_RADIUS, color.RGBA{}, fade(colour, 50), 1)
+		circle(planet.X, planet.Y, planet.Radius, fade(colour, 110), colour, 2)
+
+		for n := 0; n < planet.DockingSpots; n++ {
+			x, y := hal.Projection(planet.X, planet.Y, planet.Radius * 0.7, 360 * n / planet.DockingSpots)
+			if n < planet.DockedShips {
+				circle(x, y, 0.6, WHITE, WHITE, 1)
+			} else {
+				circle(x, y, 0.6, color.RGBA{}, WHITE, 1)
+			}
+		}
+
+		text(planet.X, planet.Y, WHITE, "P%d", planet.Id)
+		text(planet.X, planet.Y + 2.5, WHITE, "%d HP", planet.HP)
+
+		for _, ship := range game.ShipsDockedAt(planet) {
+			line(ship.X, ship.Y, planet.X, planet.Y, fade(colour, 120), 1, false)
+		}
+	}
+
+	// Ships, with HP bars above. Docked (docking, undocking) ships are outlined in white...
+
+	for _, ship := range game.AllShips() {
+
+		colour := PlayerColour(ship.Owner)
+
+		if ship.DockedStatus == hal.UNDOCKED {
+			circle(ship.X, ship.Y, hal.SHIP_RADIUS, colour, colour, 1)
+		} else {
+			circle(ship.X, ship.Y, hal.SHIP_RADIUS, colour, WHITE, 1)
+		}
+
+		bar_x := ship.X - hal.SHIP_RADIUS * 2
+		bar_y := ship.Y - hal.SHIP_RADIUS - 0.8
+		bar_length := hal.SHIP_RADIUS * 4
+
+		hp_fraction := hal.MinFloat(1, float64(ship.HP) / float64(hal.MAX_SHIP_HEALTH))
+		hp_colour := color.RGBA{uint8(255 * (1 - hp_fraction)), uint8(255 * hp_fraction), 0, 255}
+
+		line(bar_x, bar_y, bar_x + bar_length, bar_y, HP_BAR_BACK, 2, false)
+		line(bar_x, bar_y, bar_x + bar_length * hp_fraction, bar_y, hp_colour, 2, false)
+
+		text(ship.X, ship.Y + hal.SHIP_RADIUS + 1.6, colour, "%d (%d)", ship.Id, ship.HP)
+
+		// Risk: a yellow ring if it'll likely fire at Time 0, a red cross if it'll likely die then.
+
+		if ship.FireChance >= hal.RISK_FIRING {
+			circle(ship.X, ship.Y, hal.SHIP_RADIUS + 0.6, color.RGBA{}, FIRING_COLOUR, 1)
+		}
+
+		if ship.DoomChance >= hal.RISK_NAV_IGNORE {
+			d := hal.SHIP_RADIUS + 0.4
+			line(ship.X - d, ship.Y - d, ship.X + d, ship.Y + d, DOOMED_COLOUR, 2, false)
+			line(ship.X - d, ship.Y + d, ship.X + d, ship.Y - d, DOOMED_COLOUR, 2, false)
+		}
+	}
+
+	// What our pilots were thinking...
+
+	for _, pilot := range self.Pilots {
+
+		if _, ok := game.GetShip(pilot.Id); ok == false {
+			continue
+		}
+
+		if pilot.Path != nil && len(pilot.Path.Waypoints) > 0 {
+			x, y := pilot.X, pilot.Y
+			for _, p := range pilot.Path.Waypoints {
+				line(x, y, p.X, p.Y, PATH_COLOUR, 1, true)
+				x, y = p.X, p.Y
+			}
+		}
+
+		switch pilot.Target.Type() {
+		case hal.NOTHING:
+		case hal.PORT:
+			if planet, ok := game.GetPlanet(pilot.Target.GetId()); ok {
+				line(pilot.X, pilot.Y, planet.X, planet.Y, TARGET_COLOUR, 1, true)
+			}
+		default:
+			line(pilot.X, pilot.Y, pilot.Target.GetX(), pilot.Target.GetY(), TARGET_COLOUR, 1, true)
+		}
+
+		if len(pilot.Waypoints) > 0 {
+			x, y := pilot.X, pilot.Y
+			for _, p := range pilot.Waypoints {
+				line(x, y, p.X, p.Y, WAYPOINT_COLOUR, 1, true)
+				circle(p.X, p.Y, 0.4, WAYPOINT_COLOUR, WAYPOINT_COLOUR, 1)
+				x, y = p.X, p.Y
+			}
+		}
+
+		if pilot.FleePoint != nil {
+			p := pilot.FleePoint
+			line(pilot.X, pilot.Y, p.X, p.Y, FLEE_COLOUR, 1, false)
+			line(p.X - 0.7, p.Y - 0.7, p.X + 0.7, p.Y + 0.7, FLEE_COLOUR, 2, false)
+			line(p.X - 0.7, p.Y + 0.7, p.X + 0.7, p.Y - 0.7, FLEE_COLOUR, 2, false)
+		}
+
+		if speed, degrees := game.CurrentOrder(pilot.Ship).Course(); speed > 0 {
+			x, y := hal.Projection(pilot.X, pilot.Y, float64(speed), degrees)
+			line(pilot.X, pilot.Y, x, y, MOVE_COLOUR, 2, false)
+		}
+	}
+
+	text(2, 3, WHITE, "Turn %d", game.Turn())
+
+	return ret
+}
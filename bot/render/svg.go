@@ -0,0 +1,87 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// SVG is drawn in game coordinates (the viewBox is the map), scaled up by the width and height, so only
+// stroke widths (given in pixels) need converting.
+
+type svg_canvas struct {
+	b				bytes.Buffer
+	scale			float64
+}
+
+func new_svg_canvas(width, height int, scale float64) *svg_canvas {
+
+	ret := &svg_canvas{scale: scale}
+
+	fmt.Fprintf(&ret.b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %d %d">` + "\n",
+		float64(width) * scale, float64(height) * scale, width, height)
+	fmt.Fprintf(&ret.b, `<rect x="0" y="0" width="%d" height="%d" fill="%s"/>` + "\n", width, height, svg_colour(BACKGROUND))
+
+	return ret
+}
+
+func (self *svg_canvas) draw(shapes []*shape) {
+
+	for _, s := range shapes {
+
+		switch s.kind {
+
+		case CIRCLE:
+
+			fmt.Fprintf(&self.b, `<circle cx="%.3f" cy="%.3f" r="%.3f"%s%s/>` + "\n",
+				s.x1, s.y1, s.r, svg_paint("fill", s.fill), self.stroke(s))
+
+		case LINE:
+
+			fmt.Fprintf(&self.b, `<line x1="%.3f" y1="%.3f" x2="%.3f" y2="%.3f"%s/>` + "\n",
+				s.x1, s.y1, s.x2, s.y2, self.stroke(s))
+
+		case TEXT:
+
+			var escaped bytes.Buffer
+			xml.EscapeText(&escaped, []byte(s.text))
+
+			fmt.Fprintf(&self.b, `<text x="%.3f" y="%.3f" font-size="1.4" font-family="monospace" text-anchor="middle"%s>%s</text>` + "\n",
+				s.x1, s.y1, svg_paint("fill", s.fill), escaped.String())
+		}
+	}
+}
+
+func (self *svg_canvas) encode(w io.Writer) error {
+	self.b.WriteString("</svg>\n")
+	_, err := w.Write(self.b.Bytes())
+	return err
+}
+
+func (self *svg_canvas) stroke(s *shape) string {
+
+	if s.stroke.A == 0 {
+		return ` stroke="none"`
+	}
+
+	ret := svg_paint("stroke", s.stroke) + fmt.Sprintf(` stroke-width="%.3f"`, s.width / self.scale)
+
+	if s.dashed {
+		ret += fmt.Sprintf(` stroke-dasharray="%.3f"`, DASH_PIXELS / self.scale)
+	}
+
+	return ret
+}
+
+func svg_paint(attribute string, c color.RGBA) string {
+	if c.A == 0 {
+		return fmt.Sprintf(` %s="none"`, attribute)
+	}
+	return fmt.Sprintf(` %s="%s" %s-opacity="%.3f"`, attribute, svg_colour(c), attribute, float64(c.A) / 255)
+}
+
+func svg_colour(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
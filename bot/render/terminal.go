@@ -0,0 +1,331 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	hal "../core"
+	pil "../pilot"
+)
+
+// Draws a Game to an ANSI terminal, for watching games over SSH. The map is scaled to the console: each cell
+// shows the planet there in its owner's colour (with its ID at the centre), or the ships in it, as a count in
+// their owner's colour; a "*" means ships of more than one player, i.e. probably a fight. Below that, a table
+// of each player's ships, cumulative ships (the tiebreaker), planets and turn time, and a tally of what the
+// ships' messages (see pilot/messages.go) say they're up to.
+//
+// The whole frame is written at once, after a clear-screen, so calling Draw() every turn animates it.
+//
+//     view := render.NewTermView(game)
+//     view.Stats[game.Pid()].TurnTime = elapsed
+//     view.Draw(os.Stderr)
+
+const (
+	TERM_DEFAULT_COLUMNS = 100
+	TERM_MIN_COLUMNS = 20
+	TERM_CELL_ASPECT = 2.0					// A character cell is about twice as tall as it is wide
+
+	ANSI_HOME_CLEAR = "\x1b[H\x1b[2J"
+	ANSI_RESET = "\x1b[0m"
+	ANSI_BOLD = "\x1b[1m"
+)
+
+type TermStats struct {
+	Name			string
+	Cumulative		int						// As GetCumulativeShipCount()
+	TurnTime		time.Duration			// 0 if not known
+}
+
+type TermView struct {
+	Game			*hal.Game
+	Stats			map[int]*TermStats		// Player ID --> stats; NewTermView() fills in what the Game knows
+	Messages		map[int]int				// Ship ID --> message, for whichever ships we know them for
+	Columns			int						// Width of the map, in characters (not counting the border)
+	Colour			bool
+}
+
+type term_cell struct {
+	planet			*hal.Planet
+	label			byte					// Part of the planet's ID, or 0
+	ships			[]int					// Player ID --> count
+}
+
+func NewTermView(game *hal.Game) *TermView {
+
+	// The map fills the console, if $COLUMNS says how wide it is. $NO_COLOR turns colour off, as is customary.
+
+	columns := TERM_DEFAULT_COLUMNS
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		columns = n - 2
+	}
+
+	ret := &TermView{
+		Game: game,
+		Stats: make(map[int]*TermStats),
+		Messages: make(map[int]int),
+		Columns: columns,
+		Colour: os.Getenv("NO_COLOR") == "",
+	}
+
+	for pid := 0; pid < game.InitialPlayers(); pid++ {
+		ret.Stats[pid] = &TermStats{Cumulative: game.GetCumulativeShipCount(pid)}
+	}
+
+	for _, ship := range game.MyShips() {
+		if message := game.CurrentMessage(ship); message >= 0 {
+			ret.Messages[ship.Id] = message
+		}
+	}
+
+	return ret
+}
+
+func (self *TermView) Draw(w io.Writer) error {
+	var b bytes.Buffer
+	b.WriteString(ANSI_HOME_CLEAR)
+	self.draw_map(&b)
+	self.draw_table(&b)
+	self.draw_messages(&b)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// ------------------------------------------------------------------------------------------------
+
+func (self *TermView) paint(b *bytes.Buffer, c color.RGBA, bold bool, s string) {
+
+	if self.Colour == false {
+		b.WriteString(s)
+		return
+	}
+
+	if bold {
+		b.WriteString(ANSI_BOLD)
+	}
+	fmt.Fprintf(b, "\x1b[38;2;%d;%d;%dm%s%s", c.R, c.G, c.B, s, ANSI_RESET)
+}
+
+func (self *TermView) grid() [][]*term_cell {
+
+	game := self.Game
+
+	columns := hal.Max(TERM_MIN_COLUMNS, self.Columns)
+	rows := hal.Max(1, int(float64(columns) * float64(game.Height()) / float64(game.Width()) / TERM_CELL_ASPECT + 0.5))
+
+	cell_w := float64(game.Width()) / float64(columns)
+	cell_h := float64(game.Height()) / float64(rows)
+
+	ret := make([][]*term_cell, rows)
+	for y := range ret {
+		ret[y] = make([]*term_cell, columns)
+		for x := range ret[y] {
+			ret[y][x] = &term_cell{ships: make([]int, len(PLAYER_COLOURS))}
+		}
+	}
+
+	cell_at := func(x, y float64) *term_cell {
+		col := hal.Max(0, hal.Min(columns - 1, int(x / cell_w)))
+		row := hal.Max(0, hal.Min(rows - 1, int(y / cell_h)))
+		return ret[row][col]
+	}
+
+	// Planets cover every cell whose centre is inside them, plus the cell their centre is in (so small planets
+	// on a small console still show). The ID is written across the middle...
+
+	for _, planet := range game.AllPlanets() {
+
+		for y := range ret {
+			for x := range ret[y] {
+				if hal.Dist((float64(x) + 0.5) * cell_w, (float64(y) + 0.5) * cell_h, planet.X, planet.Y) < planet.Radius {
+					ret[y][x].planet = planet
+				}
+			}
+		}
+
+		cell_at(planet.X, planet.Y).planet = planet
+
+		label := strconv.Itoa(planet.Id)
+		col := hal.Max(0, hal.Min(columns - len(label), int(planet.X / cell_w) - len(label) / 2))
+		row := hal.Max(0, hal.Min(rows - 1, int(planet.Y / cell_h)))
+
+		for i := 0; i < len(label); i++ {
+			if ret[row][col + i].planet == planet {
+				ret[row][col + i].label = label[i]
+			}
+		}
+	}
+
+	// Ships, counted per player. Docked ships sit on the planet's edge, so they show over it...
+
+	for _, ship := range game.AllShips() {
+		if ship.Owner >= 0 && ship.Owner < len(PLAYER_COLOURS) {
+			cell_at(ship.X, ship.Y).ships[ship.Owner]++
+		}
+	}
+
+	return ret
+}
+
+func (self *TermView) draw_map(b *bytes.Buffer) {
+
+	game := self.Game
+	grid := self.grid()
+
+	fmt.Fprintf(b, "Turn %d  (%d x %d)\n", game.Turn(), game.Width(), game.Height())
+
+	border := "+" + strings.Repeat("-", len(grid[0])) + "+\n"
+	b.WriteString(border)
+
+	for _, row := range grid {
+
+		b.WriteString("|")
+
+		for _, cell := range row {
+
+			owners, total, owner := 0, 0, -1
+			for pid, count := range cell.ships {
+				if count > 0 {
+					owners++
+					total += count
+					owner = pid
+				}
+			}
+
+			switch {
+
+			case owners > 1:
+				self.paint(b, WHITE, true, "*")
+
+			case owners == 1:
+				glyph := "+"
+				if total < 10 {
+					glyph = strconv.Itoa(total)
+				}
+				self.paint(b, PlayerColour(owner), true, glyph)
+
+			case cell.planet != nil:
+				colour := NEUTRAL
+				if cell.planet.Owned {
+					colour = PlayerColour(cell.planet.Owner)
+				}
+				if cell.label != 0 {
+					self.paint(b, colour, true, string(cell.label))
+				} else {
+					self.paint(b, colour, false, "#")
+				}
+
+			default:
+				b.WriteString(" ")
+			}
+		}
+
+		b.WriteString("|\n")
+	}
+
+	b.WriteString(border)
+}
+
+func (self *TermView) draw_table(b *bytes.Buffer) {
+
+	game := self.Game
+
+	var pids []int
+	for pid := range self.Stats {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+
+	fmt.Fprintf(b, "%-3s  %-24s %6s %6s %8s %8s %10s\n", "pid", "name", "ships", "docked", "cumul.", "planets", "turn time")
+
+	for _, pid := range pids {
+
+		stats := self.Stats[pid]
+
+		docked := 0
+		for _, ship := range game.ShipsOwnedBy(pid) {
+			if ship.DockedStatus != hal.UNDOCKED {
+				docked++
+			}
+		}
+
+		turn_time := "-"
+		if stats.TurnTime >= time.Millisecond {
+			turn_time = stats.TurnTime.Round(time.Millisecond).String()
+		} else if stats.TurnTime > 0 {
+			turn_time = stats.TurnTime.Round(time.Microsecond).String()
+		}
+
+		name := stats.Name
+		if len(name) > 24 {
+			name = name[:24]
+		}
+
+		line := fmt.Sprintf("%-3d  %-24s %6d %6d %8d %8d %10s", pid, name,
+			len(game.ShipsOwnedBy(pid)), docked, stats.Cumulative, len(game.PlanetsOwnedBy(pid)), turn_time)
+
+		self.paint(b, PlayerColour(pid), false, line)
+		b.WriteString("\n")
+	}
+}
+
+func (self *TermView) draw_messages(b *bytes.Buffer) {
+
+	// One line per player: how many of their ships are sending each message, most common first. IDs (of the
+	// planet or ship a ship's problem is about) are all lumped together, else the line would be all IDs.
+
+	tallies := make(map[int]map[string]int)			// Player ID --> message name --> count
+
+	for sid, message := range self.Messages {
+
+		ship, ok := self.Game.GetShip(sid)
+		if ok == false {
+			continue
+		}
+
+		name := "id"
+		if message > pil.MSG_MAX_ID {
+			name = pil.MessageName(message)
+		}
+
+		if tallies[ship.Owner] == nil {
+			tallies[ship.Owner] = make(map[string]int)
+		}
+		tallies[ship.Owner][name]++
+	}
+
+	var pids []int
+	for pid := range tallies {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+
+	for _, pid := range pids {
+
+		var names []string
+		for name := range tallies[pid] {
+			names = append(names, name)
+		}
+
+		sort.Slice(names, func(a, c int) bool {
+			if tallies[pid][names[a]] != tallies[pid][names[c]] {
+				return tallies[pid][names[a]] > tallies[pid][names[c]]
+			}
+			return names[a] < names[c]
+		})
+
+		var parts []string
+		for _, name := range names {
+			parts = append(parts, fmt.Sprintf("%s %d", name, tallies[pid][name]))
+		}
+
+		self.paint(b, PlayerColour(pid), false, fmt.Sprintf("%d:", pid))
+		fmt.Fprintf(b, " %s\n", strings.Join(parts, ", "))
+	}
+}
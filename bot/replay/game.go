@@ -0,0 +1,196 @@
+package replay
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	hal "../core"
+)
+
+var docking_statuses = map[string]hal.DockedStatus{
+	"undocked": hal.UNDOCKED,
+	"docking": hal.DOCKING,
+	"docked": hal.DOCKED,
+	"undocking": hal.UNDOCKING,
+}
+
+func (self *Replay) WorldString(turn int) string {
+
+	// The world at the given turn, exactly as the engine would have sent it.
+
+	frame := self.Frames[turn]
+
+	var tokens []string
+
+	add := func(format_string string, args ...interface{}) {
+		tokens = append(tokens, fmt.Sprintf(format_string, args...))
+	}
+
+	add("%d", self.NumPlayers)
+
+	for pid := 0; pid < self.NumPlayers; pid++ {
+
+		var player_ships []*FrameShip
+		for _, ship := range frame.Ships[pid] {
+			player_ships = append(player_ships, ship)
+		}
+
+		sort.Slice(player_ships, func(a, b int) bool {
+			return player_ships[a].Id < player_ships[b].Id
+		})
+
+		add("%d %d", pid, len(player_ships))
+
+		for _, ship := range player_ships {
+
+			status, ok := docking_statuses[ship.Docking.Status]
+			if ok == false {
+				panic(fmt.Sprintf("WorldString(): unknown docking status \"%s\"", ship.Docking.Status))
+			}
+
+			docked_planet := 0
+			if ship.Docking.PlanetId != nil {
+				docked_planet = *ship.Docking.PlanetId
+			}
+
+			add("%d %f %f %d %f %f %d %d %d %d",
+				ship.Id, ship.X, ship.Y, ship.Health, ship.VelX, ship.VelY, status, docked_planet, ship.Docking.TurnsLeft, ship.Cooldown)
+		}
+	}
+
+	// Dead planets are absent from the frame, and must be absent from the output too...
+
+	var planets []*PlanetInfo
+	for _, info := range self.Planets {
+		if frame.Planets[info.Id] != nil {
+			planets = append(planets, info)
+		}
+	}
+
+	add("%d", len(planets))
+
+	for _, info := range planets {
+
+		planet := frame.Planets[info.Id]
+
+		owned, owner := 0, 0
+		if planet.Owner != nil {
+			owned, owner = 1, *planet.Owner
+		}
+
+		add("%d %f %f %d %f %d %d %d %d %d %d",
+			info.Id, info.X, info.Y, planet.Health, info.Radius, info.DockingSpots, planet.CurrentProduction, planet.RemainingProduction,
+			owned, owner, len(planet.DockedShips))
+
+		for _, sid := range planet.DockedShips {
+			add("%d", sid)
+		}
+	}
+
+	return strings.Join(tokens, " ")
+}
+
+func (self *Replay) InitString(pid int) string {
+
+	// What the engine sends at the very start: pid, map size, then the first frame.
+
+	return fmt.Sprintf("%d\n%d %d\n%s\n", pid, self.Width, self.Height, self.WorldString(0))
+}
+
+func (self *Replay) Commands(turn, pid int) string {
+
+	// What the player sent on the given turn, reconstructed. Angle messages are preserved,
+	// but the original order of the commands isn't.
+
+	var parts []string
+
+	for _, move := range self.PlayerMoves(turn, pid) {
+		parts = append(parts, move.String())
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// ------------------------------------------------------------------------------------------------
+
+// A Playback feeds the frames of a replay into a Game, one turn at a time, from the point of view
+// of some player. Because the Game is fed every frame in order, everything it works out from
+// history (e.g. ship velocities, birth turns, cumulative ship counts) is faithful. Like a real bot's
+// Game, it starts at turn -1 (the init message) and the first Next() brings it to turn 0.
+
+type Playback struct {
+	replay			*Replay
+	pid				int
+	game			*hal.Game
+}
+
+func (self *Replay) NewPlayback(pid int) *Playback {
+
+	if pid < 0 || pid >= self.NumPlayers {
+		panic(fmt.Sprintf("NewPlayback(): bad pid %d", pid))
+	}
+
+	var all []string
+
+	all = append(all, self.InitString(pid))
+	for turn := 0; turn < len(self.Frames); turn++ {
+		all = append(all, self.WorldString(turn) + "\n")
+	}
+
+	return &Playback{
+		replay: self,
+		pid: pid,
+		game: hal.NewGameFromTransport(hal.ReadOnlyTransport(strings.NewReader(strings.Join(all, "")))),
+	}
+}
+
+func (self *Playback) Game() *hal.Game {
+	return self.game
+}
+
+func (self *Playback) Turn() int {
+	return self.game.Turn()
+}
+
+func (self *Playback) Done() bool {
+	return self.game.Turn() >= len(self.replay.Frames) - 1
+}
+
+func (self *Playback) Next() bool {
+
+	// Advances the Game to the next frame. Returns false (doing nothing) if there isn't one.
+
+	if self.Done() {
+		return false
+	}
+
+	self.game.Parse()
+	return true
+}
+
+func (self *Playback) Seek(turn int) {
+
+	// Only forwards; the Game's history can't be unwound.
+
+	if turn < self.game.Turn() || turn >= len(self.replay.Frames) {
+		panic(fmt.Sprintf("Seek(): can't seek from turn %d to %d", self.game.Turn(), turn))
+	}
+
+	for self.game.Turn() < turn {
+		self.game.Parse()
+	}
+}
+
+func (self *Playback) Commands() string {
+	return self.replay.Commands(self.game.Turn(), self.pid)
+}
+
+func (self *Replay) Game(pid, turn int) *hal.Game {
+
+	// A Game at the given turn from the given player's point of view, built by replaying every frame up to it.
+
+	playback := self.NewPlayback(pid)
+	playback.Seek(turn)
+	return playback.Game()
+}
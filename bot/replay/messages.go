@@ -0,0 +1,70 @@
+package replay
+
+import (
+	hal "../core"
+)
+
+// Our ships' messages (see pilot/messages.go) that are IDs could be a planet or, when rushing, a ship; the
+// message doesn't say which. Whichever with that ID the sender is near, or heading for, is the answer. This is
+// shared by the tools that decode messages from replays.
+
+const (
+	MESSAGE_HOLD_RANGE = 20					// Anything this close counts, whatever the direction...
+	MESSAGE_ANGLE = 45						// ...otherwise it must be at most this far off the direction of travel
+)
+
+func (self *Replay) ResolveMessageID(turn, pid int, move *Move, id int) (planet_ok, ship_ok bool) {
+
+	// Both can be true (e.g. the planet is on the way to the ship), or neither.
+
+	frame := self.Frames[turn]
+	ship := frame.Ships[pid][move.ShipId]
+
+	if ship == nil {
+		return false, false
+	}
+
+	if frame.Planets[id] != nil {
+		for _, info := range self.Planets {
+			if info.Id == id {
+				planet_ok = move.Heads(ship, info.X, info.Y, info.Radius)
+			}
+		}
+	}
+
+	// When rushing, the ship is one of our docked ships, or an enemy's; never one of our mobile ships.
+
+	for _, player_ships := range frame.Ships {
+		if other, ok := player_ships[id]; ok && (other.Owner != ship.Owner || other.Docking.Status != "undocked") {
+			ship_ok = move.Heads(ship, other.X, other.Y, 0)
+		}
+	}
+
+	return planet_ok, ship_ok
+}
+
+func (self *Move) Heads(ship *FrameShip, x, y, radius float64) bool {
+
+	// Could the ship be after something at x, y? If it's near, or (moving and) heading that way, however
+	// far; an ID has already narrowed things down a lot.
+
+	d := hal.Dist(ship.X, ship.Y, x, y) - radius
+
+	if d <= MESSAGE_HOLD_RANGE {
+		return true
+	}
+
+	if self.Magnitude == 0 {
+		return false
+	}
+
+	return AngleDiff(hal.Angle(ship.X, ship.Y, x, y), self.Degrees()) <= MESSAGE_ANGLE
+}
+
+func AngleDiff(a, b int) int {
+	diff := (a - b + 720) % 360
+	if diff > 180 {
+		diff = 360 - diff
+	}
+	return diff
+}
@@ -0,0 +1,252 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+
+	hal "../core"
+)
+
+// Types for the official Halite II replay format. Frame n is the world as the bots saw it at turn n;
+// Moves[n] is what they sent back. There is one more frame than there are moves.
+
+type Replay struct {
+	Version				int								`json:"version"`
+	EngineVersion		string							`json:"engine_version,omitempty"`
+	Width				int								`json:"width"`
+	Height				int								`json:"height"`
+	NumPlayers			int								`json:"num_players"`
+	NumFrames			int								`json:"num_frames"`
+	PlayerNames			[]string						`json:"player_names"`
+	Seed				int64							`json:"seed"`
+	MapGenerator		string							`json:"map_generator"`
+	Constants			map[string]interface{}			`json:"constants"`
+	Planets				[]*PlanetInfo					`json:"planets"`
+	Frames				[]*Frame						`json:"frames"`
+	Moves				[]map[int][]map[int]*Move		`json:"moves"`			// Turn --> Player ID --> queue --> Ship ID --> Move
+	Stats				map[int]*PlayerStats			`json:"stats"`
+}
+
+type PlanetInfo struct {							// Unchanging planet info, stored once.
+	Id					int								`json:"id"`
+	X					float64							`json:"x"`
+	Y					float64							`json:"y"`
+	Radius				float64							`json:"r"`
+	Health				int								`json:"health"`
+	DockingSpots		int								`json:"docking_spots"`
+	Production			int								`json:"production"`
+}
+
+type Frame struct {
+	Ships				map[int]map[int]*FrameShip		`json:"ships"`			// Player ID --> Ship ID --> Ship
+	Planets				map[int]*FramePlanet			`json:"planets"`			// Planet ID --> Planet (destroyed planets are absent)
+	Events				[]*Event						`json:"events"`
+}
+
+type FrameShip struct {
+	Id					int								`json:"id"`
+	Owner				int								`json:"owner"`
+	X					float64							`json:"x"`
+	Y					float64							`json:"y"`
+	Health				int								`json:"health"`
+	VelX				float64							`json:"vel_x"`
+	VelY				float64							`json:"vel_y"`
+	Cooldown			int								`json:"cooldown"`
+	Docking				Docking							`json:"docking"`
+}
+
+type Docking struct {
+	Status				string							`json:"status"`			// "undocked", "docking", "docked", "undocking"
+	PlanetId			*int							`json:"planet_id,omitempty"`
+	TurnsLeft			int								`json:"turns_left,omitempty"`
+}
+
+type FramePlanet struct {
+	Id					int								`json:"id"`
+	Health				int								`json:"health"`
+	Owner				*int							`json:"owner"`			// null if unowned
+	DockedShips			[]int							`json:"docked_ships"`
+	CurrentProduction	int								`json:"current_production"`
+	RemainingProduction	int								`json:"remaining_production"`
+}
+
+type EntityRef struct {
+	Id					int								`json:"id"`
+	Owner				int								`json:"owner"`
+	Type				string							`json:"type"`				// "ship" or "planet"
+}
+
+type Event struct {
+	Event				string							`json:"event"`			// "attack", "destroyed", "spawned"
+	Entity				EntityRef						`json:"entity"`
+	Targets				[]EntityRef						`json:"targets,omitempty"`
+	Planet				*EntityRef						`json:"planet,omitempty"`
+	Time				float64							`json:"time"`
+	X					float64							`json:"x"`
+	Y					float64							`json:"y"`
+	Radius				float64							`json:"radius,omitempty"`
+}
+
+type Move struct {
+	Type				string							`json:"type"`				// "thrust", "dock", "undock"
+	ShipId				int								`json:"shipId"`
+	Owner				int								`json:"owner"`
+	QueueNumber			int								`json:"queue_number"`
+	Angle				int								`json:"angle"`
+	Magnitude			int								`json:"magnitude"`
+	PlanetId			int								`json:"planet_id"`
+}
+
+type PlayerStats struct {
+	Rank						int						`json:"rank"`
+	LastFrameAlive				int						`json:"last_frame_alive"`
+	TotalShipCount				int						`json:"total_ship_count"`
+	DamageDealt					int						`json:"damage_dealt"`
+	AverageFrameResponseTime	float64					`json:"average_frame_response_time"`
+	MaxFrameResponseTime		int						`json:"max_frame_response_time"`
+	InitResponseTime			int						`json:"init_response_time"`
+}
+
+// ------------------------------------------------------------------------------------------------
+
+func Load(filename string) (*Replay, error) {
+
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return Decode(raw)
+}
+
+func Decode(raw []byte) (*Replay, error) {
+
+	// Replays from the server are zstd-compressed; ours (and some old ones) are plain JSON.
+	// There's no zstd in the standard library, so we rely on the zstd command being installed.
+
+	if bytes.HasPrefix(raw, []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+		var err error
+		raw, err = zstd_decompress(raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ret := new(Replay)
+
+	err := json.Unmarshal(raw, ret)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ret.Frames) == 0 {
+		return nil, fmt.Errorf("replay has no frames")
+	}
+
+	return ret, nil
+}
+
+func zstd_decompress(raw []byte) ([]byte, error) {
+
+	cmd := exec.Command("zstd", "-d", "-c", "-q")
+	cmd.Stdin = bytes.NewReader(raw)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("zstd failed: %v %s", err, stderr.String())
+	}
+
+	return out, nil
+}
+
+func (self *Replay) Save(filename string) error {
+
+	outfile, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer outfile.Close()
+
+	return json.NewEncoder(outfile).Encode(self)
+}
+
+// ------------------------------------------------------------------------------------------------
+
+func (self *Replay) Turns() int {					// i.e. the number of turns where moves were made.
+	return len(self.Moves)
+}
+
+func (self *Replay) FrameShips(turn int) []*FrameShip {
+
+	// All ships in the frame, sorted by ID.
+
+	var ret []*FrameShip
+
+	for _, player_ships := range self.Frames[turn].Ships {
+		for _, ship := range player_ships {
+			ret = append(ret, ship)
+		}
+	}
+
+	sort.Slice(ret, func(a, b int) bool {
+		return ret[a].Id < ret[b].Id
+	})
+
+	return ret
+}
+
+func (self *Replay) PlayerMoves(turn, pid int) []*Move {
+
+	// The moves of one player on one turn, sorted by ship ID.
+
+	var ret []*Move
+
+	if turn < 0 || turn >= len(self.Moves) {
+		return nil
+	}
+
+	for _, queue := range self.Moves[turn][pid] {
+		for _, move := range queue {
+			ret = append(ret, move)
+		}
+	}
+
+	sort.Slice(ret, func(a, b int) bool {
+		return ret[a].ShipId < ret[b].ShipId
+	})
+
+	return ret
+}
+
+func (self *Move) Degrees() int {				// The true angle of a thrust.
+	degrees, _ := hal.DecodeAngle(self.Angle)
+	return degrees
+}
+
+func (self *Move) Message() int {				// The angle message of a thrust, or -1.
+	if self.Type != "thrust" {
+		return -1
+	}
+	_, message := hal.DecodeAngle(self.Angle)
+	return message
+}
+
+func (self *Move) String() string {				// As the bot would have sent it.
+	switch self.Type {
+	case "thrust":
+		return fmt.Sprintf("t %d %d %d", self.ShipId, self.Magnitude, self.Angle)
+	case "dock":
+		return fmt.Sprintf("d %d %d", self.ShipId, self.PlanetId)
+	case "undock":
+		return fmt.Sprintf("u %d", self.ShipId)
+	}
+	return ""
+}